@@ -0,0 +1,65 @@
+// Command fun runs programs written in the language of the tok package.
+//
+// With no arguments it is an interactive interpreter:
+//
+//	fun [-preset name] [-ops file] [-history file]
+//
+// and `fun run` executes a script:
+//
+//	fun run [-preset name] [-ops file] [-print] script
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+func main() {
+	if len(os.Args) > 1 && os.Args[1] == "run" {
+		os.Exit(runScript(os.Args[2:], os.Stdout, os.Stderr))
+	}
+	os.Exit(interactive(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+func interactive(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("fun", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	preset, opsPath := operatorFlags(flags)
+	historyPath := flags.String("history", defaultHistoryPath(), "file to keep the history of input in; empty to disable")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	interpreter, err := newInterpreter(*preset, *opsPath)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+
+	r := newRepl(&interpreter, stdout)
+	if *historyPath != "" {
+		closer, err := r.loadHistory(*historyPath)
+		if err != nil {
+			fmt.Fprintf(stderr, "could not open history: %v\n", err)
+		} else {
+			defer closer.Close()
+		}
+	}
+
+	if err := r.run(stdin); err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	return 0
+}
+
+func defaultHistoryPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".fun_history")
+}
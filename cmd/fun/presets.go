@@ -0,0 +1,232 @@
+package main
+
+import (
+	"encoding/json"
+	"erichgess/parser/tok"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// operatorConfig describes an operator to add to an interpreter.  Func names one
+// of the implementations in binaryFuncs, unaryFuncs or comparisonFuncs depending
+// on Kind, which is "binary", "unary" or "comparison".
+type operatorConfig struct {
+	Symbol string `json:"symbol"`
+	Kind   string `json:"kind"`
+	Func   string `json:"func"`
+	// Precedence of a binary operator; ExpressionPrecedence if it is not given
+	Precedence *int `json:"precedence,omitempty"`
+	// Assoc of a binary operator is "left", "right" or "non"; left if it is not given
+	Assoc string `json:"assoc,omitempty"`
+}
+
+// config is the format of an operator file.  The operators of Preset, if one is
+// named, are added before Operators.
+type config struct {
+	Preset    string           `json:"preset"`
+	Operators []operatorConfig `json:"operators"`
+}
+
+func boolToInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+var binaryFuncs = map[string]tok.BinaryOperator[int]{
+	"add": func(a, b int) int { return a + b },
+	"sub": func(a, b int) int { return a - b },
+	"mul": func(a, b int) int { return a * b },
+	"div": func(a, b int) int { return a / b },
+	"mod": func(a, b int) int { return a % b },
+	"pow": func(a, b int) int {
+		result := 1
+		for ; b > 0; b-- {
+			result *= a
+		}
+		return result
+	},
+	"band": func(a, b int) int { return a & b },
+	"bor":  func(a, b int) int { return a | b },
+	"bxor": func(a, b int) int { return a ^ b },
+	"shl":  func(a, b int) int { return a << uint(b) },
+	"shr":  func(a, b int) int { return a >> uint(b) },
+	"and":  func(a, b int) int { return boolToInt(a != 0 && b != 0) },
+	"or":   func(a, b int) int { return boolToInt(a != 0 || b != 0) },
+	"xor":  func(a, b int) int { return boolToInt((a != 0) != (b != 0)) },
+	"implies": func(a, b int) int {
+		return boolToInt(a == 0 || b != 0)
+	},
+}
+
+var unaryFuncs = map[string]tok.UnaryOperator[int]{
+	"neg":  func(a int) int { return -a },
+	"bnot": func(a int) int { return ^a },
+	"not":  func(a int) int { return boolToInt(a == 0) },
+}
+
+var comparisonFuncs = map[string]func(a, b int) bool{
+	"lt": func(a, b int) bool { return a < b },
+	"le": func(a, b int) bool { return a <= b },
+	"gt": func(a, b int) bool { return a > b },
+	"ge": func(a, b int) bool { return a >= b },
+	"eq": func(a, b int) bool { return a == b },
+	"ne": func(a, b int) bool { return a != b },
+}
+
+func precedence(p int) *int {
+	return &p
+}
+
+var comparisons = []operatorConfig{
+	{Symbol: "<", Kind: "comparison", Func: "lt"},
+	{Symbol: "<=", Kind: "comparison", Func: "le"},
+	{Symbol: ">", Kind: "comparison", Func: "gt"},
+	{Symbol: ">=", Kind: "comparison", Func: "ge"},
+	{Symbol: "==", Kind: "comparison", Func: "eq"},
+	{Symbol: "!=", Kind: "comparison", Func: "ne"},
+}
+
+// presets are the named sets of operators which can be chosen with -preset
+var presets = map[string][]operatorConfig{
+	"arithmetic": append([]operatorConfig{
+		{Symbol: "+", Func: "add"},
+		{Symbol: "-", Func: "sub"},
+		{Symbol: "*", Func: "mul", Precedence: precedence(tok.FactorPrecedence)},
+		{Symbol: "/", Func: "div", Precedence: precedence(tok.FactorPrecedence)},
+		{Symbol: "%", Func: "mod", Precedence: precedence(tok.FactorPrecedence)},
+		{Symbol: "^", Func: "pow", Precedence: precedence(tok.FactorPrecedence + 1), Assoc: "right"},
+		{Symbol: "-", Kind: "unary", Func: "neg"},
+	}, comparisons...),
+	"bitwise": append([]operatorConfig{
+		{Symbol: "|", Func: "bor"},
+		{Symbol: "^", Func: "bxor"},
+		{Symbol: "&", Func: "band", Precedence: precedence(tok.FactorPrecedence)},
+		{Symbol: "<<", Func: "shl", Precedence: precedence(tok.FactorPrecedence)},
+		{Symbol: ">>", Func: "shr", Precedence: precedence(tok.FactorPrecedence)},
+		{Symbol: "~", Kind: "unary", Func: "bnot"},
+	}, comparisons[4:]...),
+	"boolean": {
+		{Symbol: "=>", Func: "implies", Precedence: precedence(tok.ExpressionPrecedence - 1), Assoc: "right"},
+		{Symbol: "|", Func: "or"},
+		{Symbol: "^", Func: "xor"},
+		{Symbol: "&", Func: "and", Precedence: precedence(tok.FactorPrecedence)},
+		{Symbol: "!", Kind: "unary", Func: "not"},
+		comparisons[4],
+		comparisons[5],
+	},
+}
+
+func presetNames() string {
+	names := make([]string, 0, len(presets))
+	for name := range presets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// newInterpreter creates an interpreter with the operators of the named preset and then
+// those of the operator file at opsPath, if it is not empty
+func newInterpreter(preset string, opsPath string) (tok.IntInterpreter, error) {
+	interpreter := tok.NewInterpreter()
+	if preset != "" {
+		if err := addPreset(&interpreter, preset); err != nil {
+			return interpreter, err
+		}
+	}
+	if opsPath == "" {
+		return interpreter, nil
+	}
+
+	data, err := os.ReadFile(opsPath)
+	if err != nil {
+		return interpreter, err
+	}
+	var c config
+	if err := json.Unmarshal(data, &c); err != nil {
+		return interpreter, fmt.Errorf("%s: %v", opsPath, err)
+	}
+	if c.Preset != "" {
+		if err := addPreset(&interpreter, c.Preset); err != nil {
+			return interpreter, fmt.Errorf("%s: %v", opsPath, err)
+		}
+	}
+	for _, op := range c.Operators {
+		if err := addOperator(&interpreter, op); err != nil {
+			return interpreter, fmt.Errorf("%s: %v", opsPath, err)
+		}
+	}
+	return interpreter, nil
+}
+
+func addPreset(interpreter *tok.IntInterpreter, name string) error {
+	ops, ok := presets[name]
+	if !ok {
+		return fmt.Errorf("unknown preset %q; expected one of %s", name, presetNames())
+	}
+	for _, op := range ops {
+		if err := addOperator(interpreter, op); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addOperator adds op and marks it pure, since each of the built in functions only
+// depends on its operands
+func addOperator(interpreter *tok.IntInterpreter, op operatorConfig) error {
+	if err := addOperatorFunc(interpreter, op); err != nil {
+		return err
+	}
+	return interpreter.MarkPure(op.Symbol)
+}
+
+func addOperatorFunc(interpreter *tok.IntInterpreter, op operatorConfig) error {
+	switch op.Kind {
+	case "", "binary":
+		apply, ok := binaryFuncs[op.Func]
+		if !ok {
+			return fmt.Errorf("operator %s: unknown binary function %q", op.Symbol, op.Func)
+		}
+		assoc, err := parseAssoc(op.Assoc)
+		if err != nil {
+			return fmt.Errorf("operator %s: %v", op.Symbol, err)
+		}
+		p := tok.ExpressionPrecedence
+		if op.Precedence != nil {
+			p = *op.Precedence
+		}
+		return interpreter.AddBinaryOp(op.Symbol, p, assoc, apply)
+	case "unary":
+		apply, ok := unaryFuncs[op.Func]
+		if !ok {
+			return fmt.Errorf("operator %s: unknown unary function %q", op.Symbol, op.Func)
+		}
+		return interpreter.AddUnaryOp(op.Symbol, apply)
+	case "comparison":
+		compare, ok := comparisonFuncs[op.Func]
+		if !ok {
+			return fmt.Errorf("operator %s: unknown comparison function %q", op.Symbol, op.Func)
+		}
+		return interpreter.AddComparisonOp(op.Symbol, compare)
+	default:
+		return fmt.Errorf("operator %s: unknown kind %q", op.Symbol, op.Kind)
+	}
+}
+
+func parseAssoc(assoc string) (tok.Associativity, error) {
+	switch assoc {
+	case "", "left":
+		return tok.LeftAssoc, nil
+	case "right":
+		return tok.RightAssoc, nil
+	case "non":
+		return tok.NonAssoc, nil
+	default:
+		return tok.LeftAssoc, fmt.Errorf("unknown associativity %q", assoc)
+	}
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"bufio"
+	"erichgess/parser/tok"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+const (
+	prompt             = "> "
+	continuationPrompt = "... "
+)
+
+// repl reads statements from the user, executes them and prints their results.
+// Everything the user enters is kept as history and, if historyFile is set,
+// appended to it so that it is available in later sessions.
+type repl struct {
+	interpreter *tok.IntInterpreter
+	out         io.Writer
+	history     []string
+	historyFile io.Writer
+}
+
+func newRepl(interpreter *tok.IntInterpreter, out io.Writer) *repl {
+	return &repl{
+		interpreter: interpreter,
+		out:         out,
+		history:     make([]string, 0),
+	}
+}
+
+// loadHistory reads the history of earlier sessions from path and then appends
+// the input of this session to it.  A missing history file is created.
+func (r *repl) loadHistory(path string) (io.Closer, error) {
+	if data, err := os.ReadFile(path); err == nil {
+		for _, line := range strings.Split(string(data), "\n") {
+			if line != "" {
+				r.history = append(r.history, line)
+			}
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, err
+	}
+	r.historyFile = f
+	return f, nil
+}
+
+// run reads input until in is exhausted or the user quits.  A line which leaves
+// parentheses open is continued on the following lines.
+func (r *repl) run(in io.Reader) error {
+	scanner := bufio.NewScanner(in)
+	var input strings.Builder
+
+	fmt.Fprint(r.out, prompt)
+	for scanner.Scan() {
+		if input.Len() > 0 {
+			input.WriteString("\n")
+		}
+		input.WriteString(scanner.Text())
+
+		if parenDepth(input.String()) > 0 {
+			fmt.Fprint(r.out, continuationPrompt)
+			continue
+		}
+
+		text := input.String()
+		input.Reset()
+		if strings.TrimSpace(text) != "" {
+			r.record(text)
+			if quit := r.eval(text); quit {
+				return nil
+			}
+		}
+		fmt.Fprint(r.out, prompt)
+	}
+	return scanner.Err()
+}
+
+// record adds an input to the history; each line of the input is kept separately
+func (r *repl) record(text string) {
+	for _, line := range strings.Split(text, "\n") {
+		r.history = append(r.history, line)
+		if r.historyFile != nil {
+			fmt.Fprintln(r.historyFile, line)
+		}
+	}
+}
+
+// eval runs a meta-command or a program and reports whether the user asked to quit
+func (r *repl) eval(text string) bool {
+	trimmed := strings.TrimSpace(text)
+	if strings.HasPrefix(trimmed, ":") {
+		return r.command(trimmed)
+	}
+
+	r.execute(text)
+	return false
+}
+
+// execute runs a program and prints the result of its last statement
+func (r *repl) execute(text string) {
+	results, err := r.interpreter.ExecuteProgramResults(text)
+	if err != nil {
+		fmt.Fprintln(r.out, tok.FormatError(text, err))
+		return
+	}
+	if len(results) == 0 {
+		return
+	}
+
+	fmt.Fprintln(r.out, formatResult(results[len(results)-1]))
+}
+
+func (r *repl) command(text string) bool {
+	fields := strings.Fields(text)
+	switch fields[0] {
+	case ":quit", ":q":
+		return true
+	case ":help":
+		r.help()
+	case ":vars":
+		r.vars()
+	case ":funcs":
+		for _, def := range r.interpreter.Functions() {
+			fmt.Fprintln(r.out, def)
+		}
+		for _, name := range r.interpreter.HostFunctions() {
+			fmt.Fprintf(r.out, "%s (built in)\n", name)
+		}
+	case ":ops":
+		r.ops()
+	case ":reset":
+		r.interpreter.Reset()
+	case ":history":
+		for n, line := range r.history {
+			fmt.Fprintf(r.out, "%5d  %s\n", n+1, line)
+		}
+	case ":load":
+		if len(fields) != 2 {
+			fmt.Fprintln(r.out, "usage: :load file")
+			break
+		}
+		r.load(fields[1])
+	default:
+		fmt.Fprintf(r.out, "unknown command: %s (try :help)\n", fields[0])
+	}
+	return false
+}
+
+func (r *repl) help() {
+	fmt.Fprintln(r.out, `Enter statements to execute them.  A line with unclosed parentheses continues on the next line.
+:vars        list variables
+:funcs       list functions
+:ops         list operators
+:reset       remove all variables and functions
+:load file   execute the statements in a file
+:history     list previous input
+:quit        exit`)
+}
+
+func (r *repl) vars() {
+	vars := r.interpreter.Variables()
+	names := make([]string, 0, len(vars))
+	for name := range vars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		fmt.Fprintf(r.out, "%s = %v\n", name, vars[name])
+	}
+}
+
+func (r *repl) ops() {
+	for _, op := range r.interpreter.Operators() {
+		if op.Unary {
+			fmt.Fprintf(r.out, "%-4s unary\n", op.Symbol)
+		} else {
+			fmt.Fprintf(r.out, "%-4s binary  precedence %d  %s associative\n", op.Symbol, op.Precedence, op.Assoc)
+		}
+	}
+}
+
+func (r *repl) load(path string) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Fprintln(r.out, err)
+		return
+	}
+
+	text := string(data)
+	v, err := r.interpreter.ExecuteProgram(text)
+	if err != nil {
+		fmt.Fprintf(r.out, "%s: %s\n", path, tok.FormatError(text, err))
+		return
+	}
+	fmt.Fprintln(r.out, v)
+}
+
+// parenDepth counts the parentheses in text which have been opened but not closed
+func parenDepth(text string) int {
+	depth := 0
+	for _, c := range text {
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		}
+	}
+	return depth
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"erichgess/parser/tok"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newArithmeticInterpreter(t *testing.T) tok.IntInterpreter {
+	interpreter, err := newInterpreter("arithmetic", "")
+	assert.NoError(t, err)
+	return interpreter
+}
+
+func runRepl(t *testing.T, input string) string {
+	interpreter := newArithmeticInterpreter(t)
+	var out strings.Builder
+	r := newRepl(&interpreter, &out)
+	assert.NoError(t, r.run(strings.NewReader(input)))
+	return out.String()
+}
+
+func Test_ReplPrintsResults(t *testing.T) {
+	out := runRepl(t, "x = 2 * 3\ndef f a = a + 1\nf(x)\n")
+	assert.Equal(t, "> 6\n> defined f\n> 7\n> ", out)
+}
+
+func Test_ReplContinuesUnbalancedParens(t *testing.T) {
+	out := runRepl(t, "(1 +\n2\n) * 3\n(4\n")
+	assert.Equal(t, "> ... ... 9\n> ... ", out)
+}
+
+func Test_ReplPrintsErrors(t *testing.T) {
+	out := runRepl(t, "1 + y\n")
+	assert.Equal(t, "> line 1, column 5: could not find value for label: y\n1 + y\n    ^\n> ", out)
+}
+
+func Test_ReplMetaCommands(t *testing.T) {
+	out := runRepl(t, "b = 2; a = 1\ndef f x = x * 2\n:vars\n:funcs\n:reset\n:vars\n:funcs\n:bogus\n:quit\n1\n")
+	assert.Equal(t, strings.Join([]string{
+		"> 1",
+		"> defined f",
+		"> a = 1",
+		"b = 2",
+		"> def f x = (x * 2)",
+		"> > > > unknown command: :bogus (try :help)",
+		"> ",
+	}, "\n"), out)
+}
+
+func Test_ReplOps(t *testing.T) {
+	out := runRepl(t, ":ops\n")
+	assert.Contains(t, out, "*    binary  precedence 20  left associative\n")
+	assert.Contains(t, out, "-    unary\n")
+}
+
+func Test_ReplLoad(t *testing.T) {
+	dir := t.TempDir()
+	script := filepath.Join(dir, "script.fun")
+	assert.NoError(t, os.WriteFile(script, []byte("def sq x = x * x\nsq(4)\n"), 0600))
+
+	out := runRepl(t, ":load "+script+"\nsq(3)\n:load "+filepath.Join(dir, "missing.fun")+"\n")
+	assert.True(t, strings.HasPrefix(out, "> 16\n> 9\n> "), out)
+	assert.Contains(t, out, "missing.fun")
+}
+
+func Test_ReplHistoryIsKeptAcrossSessions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history")
+
+	interpreter := newArithmeticInterpreter(t)
+	r := newRepl(&interpreter, &strings.Builder{})
+	closer, err := r.loadHistory(path)
+	assert.NoError(t, err)
+	assert.NoError(t, r.run(strings.NewReader("x = 1\n(x +\n1)\n")))
+	closer.Close()
+
+	interpreter = newArithmeticInterpreter(t)
+	var out strings.Builder
+	r = newRepl(&interpreter, &out)
+	closer, err = r.loadHistory(path)
+	assert.NoError(t, err)
+	defer closer.Close()
+	assert.NoError(t, r.run(strings.NewReader(":history\n")))
+	assert.Equal(t, ">     1  x = 1\n    2  (x +\n    3  1)\n    4  :history\n> ", out.String())
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"erichgess/parser/tok"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+// runScript implements `fun run [flags] script`.  It executes every statement of the
+// script and prints the result of the last one, or of each one with -print.  The
+// returned exit code is 1 if the script fails and 2 if it is used incorrectly.
+func runScript(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("fun run", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	preset, opsPath := operatorFlags(flags)
+	printEach := flags.Bool("print", false, "print the result of every statement")
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "usage: fun run [flags] script (- for stdin)")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+
+	interpreter, err := newInterpreter(*preset, *opsPath)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+
+	path := flags.Arg(0)
+	var data []byte
+	if path == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+
+	text := string(data)
+	results, err := interpreter.ExecuteProgramResults(text)
+	if *printEach {
+		for _, r := range results {
+			fmt.Fprintln(stdout, formatResult(r))
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "%s: %s\n", path, tok.FormatError(text, err))
+		return 1
+	}
+	if !*printEach && len(results) > 0 {
+		fmt.Fprintln(stdout, formatResult(results[len(results)-1]))
+	}
+	return 0
+}
+
+// operatorFlags adds the flags which choose the operators of the interpreter
+func operatorFlags(flags *flag.FlagSet) (preset *string, opsPath *string) {
+	preset = flags.String("preset", "arithmetic", "operators to start with: "+presetNames()+"; empty for none")
+	opsPath = flags.String("ops", "", "JSON file of operators to add")
+	return preset, opsPath
+}
+
+// formatResult describes the result of a statement; a function definition has no
+// value so its name is given instead
+func formatResult(r tok.Result[int]) string {
+	if def, ok := r.Node.(tok.FuncDef); ok {
+		return fmt.Sprintf("defined %s", def.Name)
+	}
+	return fmt.Sprint(r.Value)
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeFile(t *testing.T, name, text string) string {
+	path := filepath.Join(t.TempDir(), name)
+	assert.NoError(t, os.WriteFile(path, []byte(text), 0600))
+	return path
+}
+
+func runWith(args ...string) (code int, stdout, stderr string) {
+	var out, errOut strings.Builder
+	code = runScript(args, &out, &errOut)
+	return code, out.String(), errOut.String()
+}
+
+func Test_RunPrintsLastResult(t *testing.T) {
+	script := writeFile(t, "script.fun", "def sq x = x * x\nx = 3\nsq(x) + 2 ^ 3 ^ 2\n")
+	code, stdout, stderr := runWith(script)
+	assert.Equal(t, 0, code)
+	assert.Equal(t, "521\n", stdout)
+	assert.Empty(t, stderr)
+}
+
+func Test_RunPrintsEveryResult(t *testing.T) {
+	script := writeFile(t, "script.fun", "def sq x = x * x; x = 3\nsq(x)\n")
+	code, stdout, _ := runWith("-print", script)
+	assert.Equal(t, 0, code)
+	assert.Equal(t, "defined sq\n3\n9\n", stdout)
+}
+
+func Test_RunFailingScript_IsError(t *testing.T) {
+	script := writeFile(t, "script.fun", "x = 1\n\nx + y\n")
+	code, stdout, stderr := runWith("-print", script)
+	assert.Equal(t, 1, code)
+	assert.Equal(t, "1\n", stdout)
+	assert.Equal(t, script+": line 3, column 5: could not find value for label: y\nx + y\n    ^\n", stderr)
+}
+
+func Test_RunPresets(t *testing.T) {
+	for preset, expected := range map[string]string{
+		"bitwise": "13\n",
+		"boolean": "1\n",
+	} {
+		script := writeFile(t, "script.fun", map[string]string{
+			"bitwise": "x = 12 | 1\n~~x & (1 << 4 >> 1 | x)",
+			"boolean": "a = 1; b = 0\n!b & (a => b | !b) ^ 0",
+		}[preset])
+		code, stdout, stderr := runWith("-preset", preset, script)
+		assert.Equal(t, 0, code, stderr)
+		assert.Equal(t, expected, stdout, preset)
+	}
+}
+
+func Test_RunOperatorFile(t *testing.T) {
+	ops := writeFile(t, "ops.json", `{
+		"preset": "bitwise",
+		"operators": [
+			{"symbol": "+", "func": "add"},
+			{"symbol": "**", "func": "pow", "precedence": 30, "assoc": "right"},
+			{"symbol": "<", "kind": "comparison", "func": "lt"}
+		]
+	}`)
+	script := writeFile(t, "script.fun", "if 2 ** 3 < 9 then 1 + 2 | 4 else 0")
+	code, stdout, stderr := runWith("-preset", "", "-ops", ops, script)
+	assert.Equal(t, 0, code, stderr)
+	assert.Equal(t, "7\n", stdout)
+}
+
+func Test_RunBadOperatorFile_IsError(t *testing.T) {
+	script := writeFile(t, "script.fun", "1")
+	for _, ops := range []string{
+		`{"operators": [{"symbol": "+", "func": "nope"}]}`,
+		`{"operators": [{"symbol": "+", "func": "add", "assoc": "up"}]}`,
+		`{"operators": [{"symbol": "+", "kind": "ternary", "func": "add"}]}`,
+		`{"preset": "nope"}`,
+		`not json`,
+	} {
+		code, _, stderr := runWith("-ops", writeFile(t, "ops.json", ops), script)
+		assert.Equal(t, 2, code, ops)
+		assert.NotEmpty(t, stderr, ops)
+	}
+}
+
+func Test_RunUsage_IsError(t *testing.T) {
+	code, _, _ := runWith()
+	assert.Equal(t, 2, code)
+
+	code, _, _ = runWith("-preset", "nope", "script.fun")
+	assert.Equal(t, 2, code)
+
+	code, _, stderr := runWith(filepath.Join(t.TempDir(), "missing.fun"))
+	assert.Equal(t, 1, code)
+	assert.NotEmpty(t, stderr)
+}
@@ -0,0 +1,218 @@
+package stdlib
+
+import (
+	"erichgess/parser/tok"
+	"fmt"
+	"math"
+)
+
+// InstallNumber adds the standard operators and functions to a NumberInterpreter.  The
+// operators promote integers to floats as AddNumberOp does, except that / always divides
+// as floats.  It fails if the interpreter already has an operator with one of the same
+// symbols at a different precedence.
+func InstallNumber(i *tok.NumberInterpreter) error {
+	for _, add := range []func() error{
+		func() error {
+			return tok.AddNumberOp(i, "+", tok.ExpressionPrecedence, tok.LeftAssoc,
+				func(a, b int) int { return a + b },
+				func(a, b float64) float64 { return a + b })
+		},
+		func() error {
+			return tok.AddNumberOp(i, "-", tok.ExpressionPrecedence, tok.LeftAssoc,
+				func(a, b int) int { return a - b },
+				func(a, b float64) float64 { return a - b })
+		},
+		func() error {
+			return tok.AddNumberOp(i, "*", tok.FactorPrecedence, tok.LeftAssoc,
+				func(a, b int) int { return a * b },
+				func(a, b float64) float64 { return a * b })
+		},
+		func() error {
+			return tok.AddNumberOp(i, "/", tok.FactorPrecedence, tok.LeftAssoc, nil,
+				func(a, b float64) float64 { return a / b })
+		},
+		func() error {
+			return tok.AddNumberOp(i, "%", tok.FactorPrecedence, tok.LeftAssoc,
+				func(a, b int) int { return a % b },
+				math.Mod)
+		},
+		func() error {
+			return tok.AddNumberUnaryOp(i, "-",
+				func(a int) int { return -a },
+				func(a float64) float64 { return -a })
+		},
+		func() error {
+			return tok.AddNumberComparisonOp(i, "<",
+				func(a, b int) bool { return a < b },
+				func(a, b float64) bool { return a < b })
+		},
+		func() error {
+			return tok.AddNumberComparisonOp(i, "<=",
+				func(a, b int) bool { return a <= b },
+				func(a, b float64) bool { return a <= b })
+		},
+		func() error {
+			return tok.AddNumberComparisonOp(i, ">",
+				func(a, b int) bool { return a > b },
+				func(a, b float64) bool { return a > b })
+		},
+		func() error {
+			return tok.AddNumberComparisonOp(i, ">=",
+				func(a, b int) bool { return a >= b },
+				func(a, b float64) bool { return a >= b })
+		},
+		func() error {
+			return tok.AddNumberComparisonOp(i, "==",
+				func(a, b int) bool { return a == b },
+				func(a, b float64) bool { return a == b })
+		},
+		func() error {
+			return tok.AddNumberComparisonOp(i, "!=",
+				func(a, b int) bool { return a != b },
+				func(a, b float64) bool { return a != b })
+		},
+		func() error { return i.RegisterFunc("min", numberMin) },
+		func() error { return i.RegisterFunc("max", numberMax) },
+		func() error { return i.RegisterFunc1("abs", numberAbs) },
+		func() error { return i.RegisterFunc2("pow", numberPow) },
+		func() error { return i.RegisterFunc1("sqrt", numberSqrt) },
+		func() error { return i.RegisterFunc1("floor", numberRounding(math.Floor)) },
+		func() error { return i.RegisterFunc1("ceil", numberRounding(math.Ceil)) },
+		func() error { return i.RegisterFunc1("round", numberRounding(math.Round)) },
+		func() error { return i.RegisterFunc2("mod", numberMod) },
+		func() error { return i.RegisterFunc2("gcd", numberGcd) },
+		func() error { return i.RegisterFunc3("clamp", numberClamp) },
+		func() error { return i.RegisterFunc("sum", numberSum) },
+		func() error { return i.RegisterFunc("avg", numberAvg) },
+		func() error { return i.MarkPure(operators...) },
+	} {
+		if err := add(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func less(a, b tok.Number) bool {
+	if !a.IsFloat() && !b.IsFloat() {
+		return a.Int() < b.Int()
+	}
+	return a.Float() < b.Float()
+}
+
+func numberMin(args ...tok.Number) (tok.Number, error) {
+	if len(args) == 0 {
+		return tok.Number{}, fmt.Errorf("expected at least one argument")
+	}
+	m := args[0]
+	for _, a := range args[1:] {
+		if less(a, m) {
+			m = a
+		}
+	}
+	return m, nil
+}
+
+func numberMax(args ...tok.Number) (tok.Number, error) {
+	if len(args) == 0 {
+		return tok.Number{}, fmt.Errorf("expected at least one argument")
+	}
+	m := args[0]
+	for _, a := range args[1:] {
+		if less(m, a) {
+			m = a
+		}
+	}
+	return m, nil
+}
+
+func numberAbs(x tok.Number) (tok.Number, error) {
+	if x.IsFloat() {
+		return tok.Float(math.Abs(x.Float())), nil
+	}
+	return tok.Int(intAbs(x.Int())), nil
+}
+
+func numberPow(x, n tok.Number) (tok.Number, error) {
+	if !x.IsFloat() && !n.IsFloat() && n.Int() >= 0 {
+		v, err := intPow(x.Int(), n.Int())
+		return tok.Int(v), err
+	}
+	return tok.Float(math.Pow(x.Float(), n.Float())), nil
+}
+
+func numberSqrt(x tok.Number) (tok.Number, error) {
+	if x.Float() < 0 {
+		return tok.Number{}, fmt.Errorf("square root of negative number: %s", x)
+	}
+	return tok.Float(math.Sqrt(x.Float())), nil
+}
+
+// numberRounding converts a float to an integer with round and leaves integers unchanged
+func numberRounding(round func(float64) float64) func(x tok.Number) (tok.Number, error) {
+	return func(x tok.Number) (tok.Number, error) {
+		if !x.IsFloat() {
+			return x, nil
+		}
+		r := round(x.Float())
+		if math.IsNaN(r) || math.IsInf(r, 0) {
+			return tok.Number{}, fmt.Errorf("cannot round %s to an integer", x)
+		}
+		return tok.Int(int(r)), nil
+	}
+}
+
+func numberMod(a, b tok.Number) (tok.Number, error) {
+	if !a.IsFloat() && !b.IsFloat() {
+		v, err := intMod(a.Int(), b.Int())
+		return tok.Int(v), err
+	}
+	if b.Float() == 0 {
+		return tok.Number{}, fmt.Errorf("modulo by zero")
+	}
+	m := math.Mod(a.Float(), b.Float())
+	if m != 0 && (m < 0) != (b.Float() < 0) {
+		m += b.Float()
+	}
+	return tok.Float(m), nil
+}
+
+func numberGcd(a, b tok.Number) (tok.Number, error) {
+	if a.IsFloat() || b.IsFloat() {
+		return tok.Number{}, fmt.Errorf("gcd of non-integers: %s, %s", a, b)
+	}
+	return tok.Int(intGcd(a.Int(), b.Int())), nil
+}
+
+func numberClamp(x, lo, hi tok.Number) (tok.Number, error) {
+	if less(hi, lo) {
+		return tok.Number{}, fmt.Errorf("lower bound %s is greater than upper bound %s", lo, hi)
+	}
+	if less(x, lo) {
+		return lo, nil
+	}
+	if less(hi, x) {
+		return hi, nil
+	}
+	return x, nil
+}
+
+func numberSum(args ...tok.Number) (tok.Number, error) {
+	total := tok.Int(0)
+	for _, a := range args {
+		if total.IsFloat() || a.IsFloat() {
+			total = tok.Float(total.Float() + a.Float())
+		} else {
+			total = tok.Int(total.Int() + a.Int())
+		}
+	}
+	return total, nil
+}
+
+func numberAvg(args ...tok.Number) (tok.Number, error) {
+	if len(args) == 0 {
+		return tok.Number{}, fmt.Errorf("expected at least one argument")
+	}
+	total, _ := numberSum(args...)
+	return tok.Float(total.Float() / float64(len(args))), nil
+}
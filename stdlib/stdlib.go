@@ -0,0 +1,192 @@
+// Package stdlib installs the standard arithmetic operators and a library of math
+// functions into an interpreter from the tok package.
+//
+// The operators are + and - at ExpressionPrecedence, * / and % at FactorPrecedence,
+// unary - and the comparisons < <= > >= == and !=.  They are all marked pure so that
+// the optimizer computes them ahead of time when their operands are constant.
+//
+// The functions are:
+//
+//	min(x, ...)        the smallest argument
+//	max(x, ...)        the largest argument
+//	abs(x)             the absolute value of x
+//	pow(x, n)          x raised to the power n
+//	sqrt(x)            the square root of x, which must not be negative
+//	floor(x)           the largest integer not greater than x
+//	ceil(x)            the smallest integer not less than x
+//	round(x)           the nearest integer to x, rounding halves away from zero
+//	mod(a, b)          the remainder of a divided by b with the sign of b
+//	gcd(a, b)          the greatest common divisor of a and b, which is never negative
+//	clamp(x, lo, hi)   x limited to the range lo to hi
+//	sum(x, ...)        the total of the arguments, which is 0 if there are none
+//	avg(x, ...)        the mean of the arguments
+//
+// An integer interpreter computes everything with integers: sqrt gives the integer part
+// of the square root, floor, ceil and round leave their argument unchanged, pow requires
+// n not to be negative and avg truncates toward zero.  A NumberInterpreter promotes to
+// floats as its operators do; its sqrt, avg and / always give floats and floor, ceil and
+// round always give integers.
+package stdlib
+
+import (
+	"erichgess/parser/tok"
+	"fmt"
+)
+
+// operators are the symbols of the operators which Install and InstallNumber add
+var operators = []string{"+", "-", "*", "/", "%", "<", "<=", ">", ">=", "==", "!="}
+
+// Install adds the standard operators and functions to an integer interpreter.  It fails
+// if the interpreter already has an operator with one of the same symbols at a different
+// precedence.
+func Install(i *tok.IntInterpreter) error {
+	for _, add := range []func() error{
+		func() error { return i.AddExpressionOp("+", func(a, b int) int { return a + b }) },
+		func() error { return i.AddExpressionOp("-", func(a, b int) int { return a - b }) },
+		func() error { return i.AddFactorOp("*", func(a, b int) int { return a * b }) },
+		func() error { return i.AddFactorOp("/", func(a, b int) int { return a / b }) },
+		func() error { return i.AddFactorOp("%", func(a, b int) int { return a % b }) },
+		func() error { return i.AddUnaryOp("-", func(a int) int { return -a }) },
+		func() error { return i.AddComparisonOp("<", func(a, b int) bool { return a < b }) },
+		func() error { return i.AddComparisonOp("<=", func(a, b int) bool { return a <= b }) },
+		func() error { return i.AddComparisonOp(">", func(a, b int) bool { return a > b }) },
+		func() error { return i.AddComparisonOp(">=", func(a, b int) bool { return a >= b }) },
+		func() error { return i.AddComparisonOp("==", func(a, b int) bool { return a == b }) },
+		func() error { return i.AddComparisonOp("!=", func(a, b int) bool { return a != b }) },
+		func() error { return i.RegisterFunc("min", intMin) },
+		func() error { return i.RegisterFunc("max", intMax) },
+		func() error { return i.RegisterFunc1("abs", func(x int) (int, error) { return intAbs(x), nil }) },
+		func() error { return i.RegisterFunc2("pow", intPow) },
+		func() error { return i.RegisterFunc1("sqrt", intSqrt) },
+		func() error { return i.RegisterFunc1("floor", intIdentity) },
+		func() error { return i.RegisterFunc1("ceil", intIdentity) },
+		func() error { return i.RegisterFunc1("round", intIdentity) },
+		func() error { return i.RegisterFunc2("mod", intMod) },
+		func() error { return i.RegisterFunc2("gcd", func(a, b int) (int, error) { return intGcd(a, b), nil }) },
+		func() error { return i.RegisterFunc3("clamp", intClamp) },
+		func() error { return i.RegisterFunc("sum", intSum) },
+		func() error { return i.RegisterFunc("avg", intAvg) },
+		func() error { return i.MarkPure(operators...) },
+	} {
+		if err := add(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func intMin(args ...int) (int, error) {
+	if len(args) == 0 {
+		return 0, fmt.Errorf("expected at least one argument")
+	}
+	m := args[0]
+	for _, a := range args[1:] {
+		if a < m {
+			m = a
+		}
+	}
+	return m, nil
+}
+
+func intMax(args ...int) (int, error) {
+	if len(args) == 0 {
+		return 0, fmt.Errorf("expected at least one argument")
+	}
+	m := args[0]
+	for _, a := range args[1:] {
+		if a > m {
+			m = a
+		}
+	}
+	return m, nil
+}
+
+func intAbs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+// intPow uses exponentiation by squaring
+func intPow(x, n int) (int, error) {
+	if n < 0 {
+		return 0, fmt.Errorf("negative exponent: %d", n)
+	}
+	result := 1
+	for ; n > 0; n >>= 1 {
+		if n&1 == 1 {
+			result *= x
+		}
+		x *= x
+	}
+	return result, nil
+}
+
+// intSqrt finds the integer square root with Newton's method
+func intSqrt(x int) (int, error) {
+	if x < 0 {
+		return 0, fmt.Errorf("square root of negative number: %d", x)
+	}
+	if x < 2 {
+		return x, nil
+	}
+	// x/2 + 1 is at least the square root and cannot overflow
+	r := x/2 + 1
+	for next := (r + x/r) / 2; next < r; next = (r + x/r) / 2 {
+		r = next
+	}
+	return r, nil
+}
+
+func intIdentity(x int) (int, error) {
+	return x, nil
+}
+
+func intMod(a, b int) (int, error) {
+	if b == 0 {
+		return 0, fmt.Errorf("modulo by zero")
+	}
+	m := a % b
+	if m != 0 && (m < 0) != (b < 0) {
+		m += b
+	}
+	return m, nil
+}
+
+func intGcd(a, b int) int {
+	a, b = intAbs(a), intAbs(b)
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
+
+func intClamp(x, lo, hi int) (int, error) {
+	if lo > hi {
+		return 0, fmt.Errorf("lower bound %d is greater than upper bound %d", lo, hi)
+	}
+	if x < lo {
+		return lo, nil
+	}
+	if x > hi {
+		return hi, nil
+	}
+	return x, nil
+}
+
+func intSum(args ...int) (int, error) {
+	total := 0
+	for _, a := range args {
+		total += a
+	}
+	return total, nil
+}
+
+func intAvg(args ...int) (int, error) {
+	if len(args) == 0 {
+		return 0, fmt.Errorf("expected at least one argument")
+	}
+	total, _ := intSum(args...)
+	return total / len(args), nil
+}
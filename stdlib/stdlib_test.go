@@ -0,0 +1,146 @@
+package stdlib
+
+import (
+	"erichgess/parser/tok"
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newInterpreter(t *testing.T) tok.IntInterpreter {
+	i := tok.NewInterpreter()
+	assert.NoError(t, Install(&i))
+	return i
+}
+
+func Test_Install(t *testing.T) {
+	i := newInterpreter(t)
+	for input, expected := range map[string]int{
+		"1 + 2 * 3 - 4 / 2":         5,
+		"-7 % 3":                    -1,
+		"1 <= 2 and 3 != 4":         1,
+		"min(4, 2, 8)":              2,
+		"max(4, 2, 8)":              8,
+		"min(5)":                    5,
+		"abs(-3) + abs(3)":          6,
+		"pow(2, 10)":                1024,
+		"pow(-3, 3)":                -27,
+		"pow(5, 0)":                 1,
+		"sqrt(0)":                   0,
+		"sqrt(1)":                   1,
+		"sqrt(15)":                  3,
+		"sqrt(16)":                  4,
+		"sqrt(1000000007)":          31622,
+		"floor(7) + ceil(7)":        14,
+		"round(-7)":                 -7,
+		"mod(-7, 3)":                2,
+		"mod(7, -3)":                -2,
+		"mod(6, 3)":                 0,
+		"gcd(12, 18)":               6,
+		"gcd(-12, 18)":              6,
+		"gcd(0, 0)":                 0,
+		"clamp(5, 1, 3)":            3,
+		"clamp(-5, 1, 3)":           1,
+		"clamp(2, 1, 3)":            2,
+		"sum()":                     0,
+		"sum(1, 2, 3, 4)":           10,
+		"avg(1, 2, 3, 4)":           2,
+		"avg(-3, -4)":               -3,
+		"max(sum(1, 2), pow(2, 2))": 4,
+	} {
+		v, err := i.Execute(input)
+		assert.NoError(t, err, input)
+		assert.Equal(t, expected, v, input)
+	}
+}
+
+func Test_InstallErrors_IsError(t *testing.T) {
+	i := newInterpreter(t)
+	for _, input := range []string{
+		"min()", "max()", "avg()", "pow(2, -1)", "sqrt(-4)", "mod(1, 0)", "clamp(1, 3, 2)", "abs(1, 2)", "1 / 0",
+	} {
+		_, err := i.Execute(input)
+		assert.Error(t, err, input)
+	}
+}
+
+func Test_InstallConflict_IsError(t *testing.T) {
+	i := tok.NewInterpreter()
+	i.AddFactorOp("+", func(a, b int) int { return a + b })
+	err := Install(&i)
+	var conflict *tok.OperatorConflictError
+	assert.True(t, errors.As(err, &conflict))
+}
+
+func Test_InstalledOperatorsArePure(t *testing.T) {
+	i := newInterpreter(t)
+	n := tok.NewNumberInterpreter()
+	assert.NoError(t, InstallNumber(&n))
+	for _, operators := range [][]tok.OperatorInfo{i.Operators(), n.Operators()} {
+		assert.Len(t, operators, 12)
+		for _, op := range operators {
+			assert.True(t, op.Pure, op.Symbol)
+		}
+	}
+
+	p, err := i.Compile("x * (60 * 60 * 24) + -(2 - 3) % 2")
+	assert.NoError(t, err)
+	v, err := p.Eval(map[string]int{"x": 2})
+	assert.NoError(t, err)
+	assert.Equal(t, 172801, v)
+}
+
+func Test_InstallNumber(t *testing.T) {
+	i := tok.NewNumberInterpreter()
+	assert.NoError(t, InstallNumber(&i))
+	for input, expected := range map[string]tok.Number{
+		"1 + 2.5":          tok.Float(3.5),
+		"7 / 2":            tok.Float(3.5),
+		"7 % 2":            tok.Int(1),
+		"7.5 % 2":          tok.Float(1.5),
+		"1.5 < 2":          tok.Int(1),
+		"min(3, 2.5, 4)":   tok.Float(2.5),
+		"max(3, 2.5)":      tok.Int(3),
+		"abs(-2.5)":        tok.Float(2.5),
+		"abs(-2)":          tok.Int(2),
+		"pow(2, 3)":        tok.Int(8),
+		"pow(2, -1)":       tok.Float(0.5),
+		"pow(4, 0.5)":      tok.Float(2),
+		"sqrt(16)":         tok.Float(4),
+		"floor(-2.5)":      tok.Int(-3),
+		"ceil(2.1)":        tok.Int(3),
+		"round(2.5)":       tok.Int(3),
+		"round(-2.5)":      tok.Int(-3),
+		"round(4)":         tok.Int(4),
+		"mod(-7, 3)":       tok.Int(2),
+		"mod(-7.5, 2)":     tok.Float(0.5),
+		"gcd(12, 18)":      tok.Int(6),
+		"clamp(2.5, 1, 2)": tok.Int(2),
+		"clamp(1.5, 1, 2)": tok.Float(1.5),
+		"sum(1, 2)":        tok.Int(3),
+		"sum(1, 2.5)":      tok.Float(3.5),
+		"avg(1, 2)":        tok.Float(1.5),
+	} {
+		v, err := i.Execute(input)
+		assert.NoError(t, err, input)
+		assert.Equal(t, expected, v, input)
+	}
+}
+
+func Test_InstallNumberErrors_IsError(t *testing.T) {
+	i := tok.NewNumberInterpreter()
+	assert.NoError(t, InstallNumber(&i))
+	for _, input := range []string{
+		"gcd(1.5, 2)", "sqrt(-1)", "mod(1.5, 0)", "min()", "avg()", "clamp(1, 2, 1.5)",
+	} {
+		_, err := i.Execute(input)
+		assert.Error(t, err, input)
+	}
+
+	// rounding a float which is not finite has no integer result
+	assert.NoError(t, i.RegisterFunc0("inf", func() (tok.Number, error) { return tok.Float(math.Inf(1)), nil }))
+	_, err := i.Execute("floor(inf())")
+	assert.Error(t, err)
+}
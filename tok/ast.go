@@ -0,0 +1,240 @@
+package tok
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Node is a single element of the abstract syntax tree produced by Parse.  A
+// Node can be evaluated by an Interpreter with Eval or inspected and transformed
+// by tooling without re-tokenizing the source text.
+//
+// Each Node records the Span of source text it was parsed from.
+type Node interface {
+	fmt.Stringer
+	Pos() Span
+	node()
+}
+
+// Literal is a numeric constant.  Its Text is converted into a value by the
+// Domain of the Interpreter which evaluates it.
+type Literal struct {
+	Text string
+	Span
+}
+
+// Label is a reference to a bound variable
+type Label struct {
+	Name string
+	Span
+}
+
+// UnaryOp applies the unary operator Op to Operand
+type UnaryOp struct {
+	Op      string
+	Operand Node
+	Span
+}
+
+// BinaryOp applies the binary operator Op to Left and Right
+type BinaryOp struct {
+	Op    string
+	Left  Node
+	Right Node
+	Span
+}
+
+// LogicalOp combines Left and Right with the short circuiting keyword Op, which
+// is either `and` or `or`.  Right is only evaluated when it can change the result.
+type LogicalOp struct {
+	Op    string
+	Left  Node
+	Right Node
+	Span
+}
+
+// Not negates the truth of Operand
+type Not struct {
+	Operand Node
+	Span
+}
+
+// Call invokes the function Name with the given arguments
+type Call struct {
+	Name string
+	Args []Node
+	Span
+}
+
+// Apply calls the function which Func evaluates to with the given arguments
+type Apply struct {
+	Func Node
+	Args []Node
+	Span
+}
+
+// Lambda is an anonymous function which takes Params and computes Body.  Body may
+// use any label which is bound where the Lambda is evaluated.
+type Lambda struct {
+	Params []string
+	Body   Node
+	Span
+}
+
+// Conditional evaluates Then if Cond is true and Else otherwise
+type Conditional struct {
+	Cond Node
+	Then Node
+	Else Node
+	Span
+}
+
+// Let binds each of Bindings in order, so that a binding may use the ones before it,
+// and computes Body.  The bindings are only visible inside of the Let.
+type Let struct {
+	Bindings []Assignment
+	Body     Node
+	Span
+}
+
+// Assignment binds the result of Value to Label
+type Assignment struct {
+	Label string
+	Value Node
+	Span
+}
+
+// FuncDef defines a function called Name which takes Params and computes Body
+type FuncDef struct {
+	Name   string
+	Params []string
+	Body   Node
+	Span
+}
+
+func (Literal) node()     {}
+func (Label) node()       {}
+func (UnaryOp) node()     {}
+func (BinaryOp) node()    {}
+func (LogicalOp) node()   {}
+func (Not) node()         {}
+func (Call) node()        {}
+func (Apply) node()       {}
+func (Lambda) node()      {}
+func (Conditional) node() {}
+func (Let) node()         {}
+func (Assignment) node()  {}
+func (FuncDef) node()     {}
+
+func (n Literal) String() string {
+	return n.Text
+}
+
+func (n Label) String() string {
+	return n.Name
+}
+
+func (n UnaryOp) String() string {
+	return fmt.Sprintf("%s%s", n.Op, n.Operand)
+}
+
+// String renders the operation fully parenthesized so that the structure
+// of the tree is unambiguous
+func (n BinaryOp) String() string {
+	return fmt.Sprintf("(%s %s %s)", n.Left, n.Op, n.Right)
+}
+
+func (n LogicalOp) String() string {
+	return fmt.Sprintf("(%s %s %s)", n.Left, n.Op, n.Right)
+}
+
+func (n Not) String() string {
+	return fmt.Sprintf("(not %s)", n.Operand)
+}
+
+func (n Call) String() string {
+	return fmt.Sprintf("%s(%s)", n.Name, joinNodes(n.Args))
+}
+
+func (n Apply) String() string {
+	return fmt.Sprintf("%s(%s)", n.Func, joinNodes(n.Args))
+}
+
+func (n Lambda) String() string {
+	head := append([]string{"\\"}, n.Params...)
+	return fmt.Sprintf("(%s -> %s)", strings.Join(head, " "), n.Body)
+}
+
+func joinNodes(nodes []Node) string {
+	s := make([]string, len(nodes))
+	for i, n := range nodes {
+		s[i] = n.String()
+	}
+	return strings.Join(s, ", ")
+}
+
+func (n Conditional) String() string {
+	return fmt.Sprintf("(if %s then %s else %s)", n.Cond, n.Then, n.Else)
+}
+
+func (n Let) String() string {
+	bindings := make([]string, len(n.Bindings))
+	for i, b := range n.Bindings {
+		bindings[i] = b.String()
+	}
+	return fmt.Sprintf("(let %s in %s)", strings.Join(bindings, ", "), n.Body)
+}
+
+func (n Assignment) String() string {
+	return fmt.Sprintf("%s = %s", n.Label, n.Value)
+}
+
+func (n FuncDef) String() string {
+	head := append([]string{"def", n.Name}, n.Params...)
+	return fmt.Sprintf("%s = %s", strings.Join(head, " "), n.Body)
+}
+
+// Inspect traverses the tree rooted at node in depth first order.  It calls f
+// for each node; if f returns false then the children of that node are skipped.
+func Inspect(node Node, f func(Node) bool) {
+	if node == nil || !f(node) {
+		return
+	}
+
+	switch n := node.(type) {
+	case UnaryOp:
+		Inspect(n.Operand, f)
+	case BinaryOp:
+		Inspect(n.Left, f)
+		Inspect(n.Right, f)
+	case LogicalOp:
+		Inspect(n.Left, f)
+		Inspect(n.Right, f)
+	case Not:
+		Inspect(n.Operand, f)
+	case Call:
+		for _, a := range n.Args {
+			Inspect(a, f)
+		}
+	case Apply:
+		Inspect(n.Func, f)
+		for _, a := range n.Args {
+			Inspect(a, f)
+		}
+	case Lambda:
+		Inspect(n.Body, f)
+	case Conditional:
+		Inspect(n.Cond, f)
+		Inspect(n.Then, f)
+		Inspect(n.Else, f)
+	case Let:
+		for _, b := range n.Bindings {
+			Inspect(b, f)
+		}
+		Inspect(n.Body, f)
+	case Assignment:
+		Inspect(n.Value, f)
+	case FuncDef:
+		Inspect(n.Body, f)
+	}
+}
@@ -0,0 +1,104 @@
+package tok
+
+import (
+	"fmt"
+	"reflect"
+)
+
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+// Bind makes any Go function callable from programs by name, converting its arguments
+// and results automatically.  Each parameter and the first result may be of type T, bool,
+// or any integer or floating point type when T is also numeric; other numeric types are
+// converted to and from T as by a Go conversion, and bools use the truthiness of the
+// Interpreter.  fn must return one result or a result and an error, and may be variadic.
+// For example an IntInterpreter can bind math.Max, func(float64, float64) float64.
+//
+// Bind fails if the signature of fn cannot be converted.  Calls are checked for the number
+// of arguments as with RegisterFunc.
+func (i *Interpreter[T]) Bind(name string, fn interface{}) error {
+	f := reflect.ValueOf(fn)
+	if f.Kind() != reflect.Func || f.IsNil() {
+		return fmt.Errorf("cannot bind %s: expected a function got %T", name, fn)
+	}
+
+	ft := f.Type()
+	domainType := reflect.TypeOf((*T)(nil)).Elem()
+	for p := 0; p < ft.NumIn(); p++ {
+		in := ft.In(p)
+		if ft.IsVariadic() && p == ft.NumIn()-1 {
+			in = in.Elem()
+		}
+		if !convertible(in, domainType) {
+			return fmt.Errorf("cannot bind %s: parameter %d of type %s cannot be converted from %s", name, p+1, in, domainType)
+		}
+	}
+	if ft.NumOut() < 1 || ft.NumOut() > 2 || (ft.NumOut() == 2 && ft.Out(1) != errorType) {
+		return fmt.Errorf("cannot bind %s: must return a value or a value and an error", name)
+	}
+	if !convertible(ft.Out(0), domainType) {
+		return fmt.Errorf("cannot bind %s: result of type %s cannot be converted to %s", name, ft.Out(0), domainType)
+	}
+
+	arity := ft.NumIn()
+	if ft.IsVariadic() {
+		arity = -1
+	}
+
+	return i.registerHost(name, arity, func(args []T) (T, error) {
+		var zero T
+		if ft.IsVariadic() && len(args) < ft.NumIn()-1 {
+			return zero, &ArityError{Function: name, Expected: ft.NumIn() - 1, Got: len(args)}
+		}
+
+		in := make([]reflect.Value, len(args))
+		for a, arg := range args {
+			var pt reflect.Type
+			if ft.IsVariadic() && a >= ft.NumIn()-1 {
+				pt = ft.In(ft.NumIn() - 1).Elem()
+			} else {
+				pt = ft.In(a)
+			}
+			in[a] = i.toGo(arg, pt)
+		}
+
+		out := f.Call(in)
+		if len(out) == 2 && !out[1].IsNil() {
+			return zero, out[1].Interface().(error)
+		}
+		return i.fromGo(out[0], domainType), nil
+	})
+}
+
+// convertible is true if values of the Go type goType can be converted to and from the
+// domain.  bools are converted with the truthiness of the Interpreter.
+func convertible(goType, domainType reflect.Type) bool {
+	return goType == domainType || goType.Kind() == reflect.Bool || isNumeric(goType) && isNumeric(domainType)
+}
+
+func isNumeric(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// toGo converts an argument from the domain to the type of a parameter
+func (i *Interpreter[T]) toGo(v T, to reflect.Type) reflect.Value {
+	rv := reflect.ValueOf(&v).Elem()
+	if to.Kind() == reflect.Bool && rv.Kind() != reflect.Bool {
+		return reflect.ValueOf(i.domain.Truthy(v)).Convert(to)
+	}
+	return rv.Convert(to)
+}
+
+// fromGo converts a result to the domain
+func (i *Interpreter[T]) fromGo(v reflect.Value, domainType reflect.Type) T {
+	if v.Kind() == reflect.Bool && domainType.Kind() != reflect.Bool {
+		return i.domain.FromBool(v.Bool())
+	}
+	return v.Convert(domainType).Interface().(T)
+}
@@ -0,0 +1,104 @@
+package tok
+
+import (
+	"errors"
+	"math"
+	"math/big"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Bind(t *testing.T) {
+	i := NewInterpreter()
+	i.AddExpressionOp("+", func(a, b int) int { return a + b })
+	assert.NoError(t, i.Bind("max", math.Max))
+	assert.NoError(t, i.Bind("sqrt", math.Sqrt))
+	assert.NoError(t, i.Bind("double", func(a int) int { return a * 2 }))
+	assert.NoError(t, i.Bind("small", func(a int8) uint16 { return uint16(a) + 1 }))
+	assert.NoError(t, i.Bind("even", func(a int) bool { return a%2 == 0 }))
+	assert.NoError(t, i.Bind("choose", func(c bool, a, b int) int {
+		if c {
+			return a
+		}
+		return b
+	}))
+	assert.NoError(t, i.Bind("sum", func(first int, rest ...int64) int64 {
+		total := int64(first)
+		for _, r := range rest {
+			total += r
+		}
+		return total
+	}))
+	assert.NoError(t, i.Bind("half", func(a float64) (float64, error) { return a / 2, nil }))
+
+	for input, expected := range map[string]int{
+		"max(3, 7)":             7,
+		"sqrt(17)":              4,
+		"double(4) + 1":         9,
+		"small(4)":              5,
+		"even(4) + even(3)":     1,
+		"choose(0, 1, 2)":       2,
+		"choose(even(2), 1, 2)": 1,
+		"sum(1)":                1,
+		"sum(1, 2, 3, 4)":       10,
+		"half(7)":               3,
+	} {
+		v, err := i.Execute(input)
+		assert.NoError(t, err, input)
+		assert.Equal(t, expected, v, input)
+	}
+}
+
+func Test_BindCallErrors_IsError(t *testing.T) {
+	i := NewInterpreter()
+	parseErr := errors.New("cannot parse")
+	assert.NoError(t, i.Bind("fail", func(a int) (int, error) { return 0, parseErr }))
+	assert.NoError(t, i.Bind("sum", func(first int, rest ...int) int { return first }))
+	assert.NoError(t, i.Bind("max", math.Max))
+
+	_, err := i.Execute("fail(1)")
+	assert.True(t, errors.Is(err, parseErr))
+
+	var arityErr *ArityError
+	_, err = i.Execute("sum()")
+	assert.True(t, errors.As(err, &arityErr))
+	assert.Equal(t, ArityError{Function: "sum", Expected: 1, Got: 0}, *arityErr)
+
+	_, err = i.Execute("max(1)")
+	assert.True(t, errors.As(err, &arityErr))
+	assert.Equal(t, ArityError{Function: "max", Expected: 2, Got: 1}, *arityErr)
+}
+
+func Test_BindSignatureMismatch_IsError(t *testing.T) {
+	i := NewInterpreter()
+	for name, fn := range map[string]interface{}{
+		"notAFunc":     42,
+		"nilFunc":      (func(int) int)(nil),
+		"stringParam":  func(s string) int { return len(s) },
+		"stringResult": strconv.Itoa,
+		"noResult":     func(a int) {},
+		"tooMany":      func(a int) (int, int, error) { return a, a, nil },
+		"notError":     func(a int) (int, int) { return a, a },
+		"variadicStr":  func(a ...string) int { return 0 },
+	} {
+		assert.Error(t, i.Bind(name, fn), name)
+	}
+	assert.Error(t, i.Bind("if", func(a int) int { return a }))
+}
+
+func Test_BindWithNonNumericDomain(t *testing.T) {
+	i := New(BigIntDomain())
+	assert.NoError(t, i.Bind("neg", func(a *big.Int) *big.Int { return new(big.Int).Neg(a) }))
+	assert.NoError(t, i.Bind("isZero", func(a *big.Int) bool { return a.Sign() == 0 }))
+	assert.Error(t, i.Bind("half", func(a float64) float64 { return a / 2 }))
+
+	v, err := i.Execute("neg(12345678901234567890)")
+	assert.NoError(t, err)
+	assert.Equal(t, "-12345678901234567890", v.String())
+
+	v, err = i.Execute("isZero(0)")
+	assert.NoError(t, err)
+	assert.Equal(t, "1", v.String())
+}
@@ -0,0 +1,392 @@
+package tok
+
+// opcode is an instruction of the virtual machine which runs compiled nodes
+type opcode uint8
+
+const (
+	opConst           opcode = iota // push consts[arg]
+	opLoad                          // push the value of the label names[arg]
+	opLocal                         // push the parameter in slot arg
+	opFunction                      // push the function names[arg]
+	opStore                         // bind the label names[arg] to the value on top of the stack
+	opDefine                        // define the function of the FuncDef node and push zero
+	opScalar                        // fail if the value on top of the stack is a function
+	opUnary                         // apply the unary operator names[arg] to the top value
+	opBinary                        // apply the binary operator names[arg] to the top two values
+	opNot                           // replace the top value with its negation
+	opBool                          // replace the top value with FromBool of its truthiness
+	opTrue                          // push FromBool(true)
+	opFalse                         // push FromBool(false)
+	opJump                          // continue at arg
+	opJumpIfFalse                   // pop the top value and continue at arg if it is false
+	opJumpIfTrue                    // pop the top value and continue at arg if it is true
+	opResolve                       // push the function called names[arg]
+	opResolveLocal                  // push the parameter in slot argc if it is a function, else as opResolve
+	opResolveFunc                   // push the function names[arg], ignoring variables
+	opCallable                      // fail if the value on top of the stack is not a function
+	opApply                         // call the function below the top argc values with them as arguments
+	opSlide                         // remove the arg values below the value on top of the stack
+	opCaptured                      // push the value captured by the running closure at index arg
+	opResolveCaptured               // push the captured value at index argc if it is a function, else as opResolveFunc
+	opClosure                       // create a closure of lambdas[arg] which captures the top argc values
+)
+
+// instruction is a single step of a chunk.  node is the node which the instruction was
+// compiled from and gives the position of any error it causes.
+type instruction struct {
+	op   opcode
+	arg  int
+	argc int
+	node Node
+}
+
+// chunk is a node compiled into instructions for the virtual machine
+type chunk[T any] struct {
+	code    []instruction
+	consts  []value[T]
+	names   []string
+	lambdas []compiledLambda[T]
+}
+
+// compiledLambda is the body of a lambda compiled with its parameters in slots and the
+// labels it captures from the function around it read from its closure.  A lambda which
+// is scoped is outside of any function, so it looks up other labels in the scope where
+// its closure was created.
+type compiledLambda[T any] struct {
+	lambda Lambda
+	code   *chunk[T]
+	scoped bool
+}
+
+type compiler[T any] struct {
+	interpreter *Interpreter[T]
+	chunk       *chunk[T]
+	names       map[string]int
+
+	// locals maps the parameters of the function being compiled and the labels bound
+	// by let to their slots in the stack
+	locals map[string]int
+
+	// function is true when compiling the body of a function, which can only use
+	// labels which are local or the names of functions
+	function bool
+
+	// depth is the number of values which will be on the stack above the base when
+	// the next instruction runs
+	depth int
+
+	// parent is the compiler of the function around a lambda, from which the lambda
+	// captures the labels in captures
+	parent   *compiler[T]
+	captures map[string]int
+}
+
+// compile converts a statement into a chunk.  It returns nil if the statement cannot
+// be compiled, such as when it uses an operator which has not been added.
+func (i *Interpreter[T]) compile(node Node) *chunk[T] {
+	if i.walk {
+		return nil
+	}
+	c := compiler[T]{interpreter: i, chunk: &chunk[T]{}, names: make(map[string]int), locals: make(map[string]int)}
+	if !c.node(node) {
+		return nil
+	}
+	return c.chunk
+}
+
+// compileFunction converts the body of a function into a chunk in which the parameters
+// are read from slots rather than looked up by name.  It returns nil if the body cannot
+// be compiled.
+func (i *Interpreter[T]) compileFunction(params []string, body Node) *chunk[T] {
+	return i.compileBody(params, body, true)
+}
+
+// compileLambda converts the body of a lambda which was reached by walking the tree into
+// a chunk, so that its closure is called in a frame.  The labels which are not its
+// parameters are looked up in the scope of the closure.
+func (i *Interpreter[T]) compileLambda(n Lambda) *chunk[T] {
+	return i.compileBody(n.Params, n.Body, false)
+}
+
+func (i *Interpreter[T]) compileBody(params []string, body Node, function bool) *chunk[T] {
+	if i.walk {
+		return nil
+	}
+	c := compiler[T]{interpreter: i, chunk: &chunk[T]{}, names: make(map[string]int), locals: make(map[string]int, len(params)), function: function}
+	for slot, p := range params {
+		c.locals[p] = slot
+	}
+	c.depth = len(params)
+	if !c.node(body) {
+		return nil
+	}
+	return c.chunk
+}
+
+func (c *compiler[T]) emit(op opcode, arg, argc int, node Node) int {
+	c.chunk.code = append(c.chunk.code, instruction{op: op, arg: arg, argc: argc, node: node})
+
+	switch op {
+	case opConst, opLoad, opLocal, opCaptured, opFunction, opDefine, opTrue, opFalse,
+		opResolve, opResolveLocal, opResolveCaptured, opResolveFunc:
+		c.depth++
+	case opBinary, opJumpIfFalse, opJumpIfTrue:
+		c.depth--
+	case opApply:
+		c.depth -= argc
+	case opSlide:
+		c.depth -= arg
+	case opClosure:
+		c.depth -= argc - 1
+	}
+	return len(c.chunk.code) - 1
+}
+
+// patch points the jump at pc to the next instruction
+func (c *compiler[T]) patch(pc int) {
+	c.chunk.code[pc].arg = len(c.chunk.code)
+}
+
+// local finds a label which is bound inside of the function being compiled, either in
+// a slot or, in a lambda, captured from the function around it
+func (c *compiler[T]) local(name string) (opcode, int, bool) {
+	if slot, ok := c.locals[name]; ok {
+		return opLocal, slot, true
+	}
+	if c.parent == nil {
+		return 0, 0, false
+	}
+	if index, ok := c.captures[name]; ok {
+		return opCaptured, index, true
+	}
+	if _, _, ok := c.parent.local(name); !ok {
+		return 0, 0, false
+	}
+	c.captures[name] = len(c.captures)
+	return opCaptured, c.captures[name], true
+}
+
+func (c *compiler[T]) name(name string) int {
+	if n, ok := c.names[name]; ok {
+		return n
+	}
+	c.chunk.names = append(c.chunk.names, name)
+	c.names[name] = len(c.chunk.names) - 1
+	return c.names[name]
+}
+
+func (c *compiler[T]) node(node Node) bool {
+	switch n := node.(type) {
+	case Literal:
+		v, err := c.interpreter.domain.ParseLiteral(n.Text)
+		if err != nil {
+			return false
+		}
+		c.chunk.consts = append(c.chunk.consts, value[T]{v: v})
+		c.emit(opConst, len(c.chunk.consts)-1, 0, n)
+	case constant[T]:
+		c.chunk.consts = append(c.chunk.consts, value[T]{v: n.v})
+		c.emit(opConst, len(c.chunk.consts)-1, 0, n)
+	case Label:
+		if op, index, ok := c.local(n.Name); ok {
+			c.emit(op, index, 0, n)
+		} else if c.function {
+			// the body of a function can only name its parameters and functions
+			c.emit(opFunction, c.name(n.Name), 0, n)
+		} else {
+			c.emit(opLoad, c.name(n.Name), 0, n)
+		}
+	case UnaryOp:
+		if _, ok := c.interpreter.unaryOps[n.Op]; !ok || !c.scalar(n.Operand) {
+			return false
+		}
+		c.emit(opUnary, c.name(n.Op), 0, n)
+	case BinaryOp:
+		if _, ok := c.interpreter.binaryOps[n.Op]; !ok || !c.scalar(n.Left) || !c.scalar(n.Right) {
+			return false
+		}
+		c.emit(opBinary, c.name(n.Op), 0, n)
+	case Not:
+		if !c.scalar(n.Operand) {
+			return false
+		}
+		c.emit(opNot, 0, 0, n)
+	case LogicalOp:
+		return c.logicalOp(n)
+	case Conditional:
+		// the optimizer leaves a condition which it computed for only one branch
+		if cond, ok := n.Cond.(constant[T]); ok {
+			if c.interpreter.domain.Truthy(cond.v) {
+				return c.node(n.Then)
+			}
+			return c.node(n.Else)
+		}
+		if !c.scalar(n.Cond) {
+			return false
+		}
+		toElse := c.emit(opJumpIfFalse, 0, 0, n.Cond)
+		if !c.node(n.Then) {
+			return false
+		}
+		toEnd := c.emit(opJump, 0, 0, n)
+		c.patch(toElse)
+		c.depth--
+		if !c.node(n.Else) {
+			return false
+		}
+		c.patch(toEnd)
+	case Let:
+		return c.let(n)
+	case Lambda:
+		return c.lambda(n)
+	case Call:
+		if op, index, ok := c.local(n.Name); ok && op == opLocal {
+			c.emit(opResolveLocal, c.name(n.Name), index, n)
+		} else if ok {
+			c.emit(opResolveCaptured, c.name(n.Name), index, n)
+		} else if c.function {
+			c.emit(opResolveFunc, c.name(n.Name), 0, n)
+		} else {
+			c.emit(opResolve, c.name(n.Name), 0, n)
+		}
+		return c.apply(n.Args, n)
+	case Apply:
+		if !c.node(n.Func) {
+			return false
+		}
+		c.emit(opCallable, 0, 0, n.Func)
+		return c.apply(n.Args, n)
+	case Assignment:
+		if c.function || !c.node(n.Value) {
+			return false
+		}
+		c.emit(opStore, c.name(n.Label), 0, n)
+	case FuncDef:
+		if c.function {
+			return false
+		}
+		c.emit(opDefine, 0, 0, n)
+	default:
+		return false
+	}
+	return true
+}
+
+// scalar compiles a node whose value must not be a function.  Only the nodes which can
+// evaluate to a function are checked.
+func (c *compiler[T]) scalar(node Node) bool {
+	if !c.node(node) {
+		return false
+	}
+	switch node.(type) {
+	case Label, Call, Apply, Conditional, Lambda, Let:
+		c.emit(opScalar, 0, 0, node)
+	}
+	return true
+}
+
+// logicalOp compiles `and` and `or` so that the right operand is jumped over when the
+// left operand decides the result
+func (c *compiler[T]) logicalOp(n LogicalOp) bool {
+	jump, short := opJumpIfFalse, opFalse
+	switch n.Op {
+	case "and":
+	case "or":
+		jump, short = opJumpIfTrue, opTrue
+	default:
+		return false
+	}
+
+	if !c.scalar(n.Left) {
+		return false
+	}
+	toShort := c.emit(jump, 0, 0, n.Left)
+	if !c.scalar(n.Right) {
+		return false
+	}
+	c.emit(opBool, 0, 0, n.Right)
+	toEnd := c.emit(opJump, 0, 0, n)
+	c.patch(toShort)
+	c.depth--
+	c.emit(short, 0, 0, n)
+	c.patch(toEnd)
+	return true
+}
+
+// let compiles the bindings of n into slots which hold their values while the body
+// runs, after which they are removed from beneath the result
+func (c *compiler[T]) let(n Let) bool {
+	shadowed := make(map[string]int)
+	for _, b := range n.Bindings {
+		if !c.node(b.Value) {
+			return false
+		}
+		if slot, ok := c.locals[b.Label]; ok {
+			if _, saved := shadowed[b.Label]; !saved {
+				shadowed[b.Label] = slot
+			}
+		} else if _, saved := shadowed[b.Label]; !saved {
+			shadowed[b.Label] = -1
+		}
+		c.locals[b.Label] = c.depth - 1
+	}
+
+	if !c.node(n.Body) {
+		return false
+	}
+	c.emit(opSlide, len(n.Bindings), 0, n)
+
+	for label, slot := range shadowed {
+		if slot < 0 {
+			delete(c.locals, label)
+		} else {
+			c.locals[label] = slot
+		}
+	}
+	return true
+}
+
+// lambda compiles the body of n into its own chunk.  The labels it captures are pushed
+// in the order of their indexes and then taken by the closure.  Parameters and labels
+// bound by let never change, so capturing their values captures the bindings.
+func (c *compiler[T]) lambda(n Lambda) bool {
+	inner := compiler[T]{
+		interpreter: c.interpreter,
+		chunk:       &chunk[T]{},
+		names:       make(map[string]int),
+		locals:      make(map[string]int, len(n.Params)),
+		function:    c.function,
+		depth:       len(n.Params),
+		parent:      c,
+		captures:    make(map[string]int),
+	}
+	for slot, p := range n.Params {
+		inner.locals[p] = slot
+	}
+	if !inner.node(n.Body) {
+		return false
+	}
+
+	captured := make([]string, len(inner.captures))
+	for name, index := range inner.captures {
+		captured[index] = name
+	}
+	for _, name := range captured {
+		op, index, _ := c.local(name)
+		c.emit(op, index, 0, n)
+	}
+
+	c.chunk.lambdas = append(c.chunk.lambdas, compiledLambda[T]{lambda: n, code: inner.chunk, scoped: !c.function})
+	c.emit(opClosure, len(c.chunk.lambdas)-1, len(captured), n)
+	return true
+}
+
+func (c *compiler[T]) apply(args []Node, call Node) bool {
+	for _, arg := range args {
+		if !c.node(arg) {
+			return false
+		}
+	}
+	c.emit(opApply, 0, len(args), call)
+	return true
+}
@@ -0,0 +1,121 @@
+package tok
+
+import (
+	"fmt"
+	"math/big"
+	"strconv"
+)
+
+// Domain describes the values that an Interpreter computes with.  All of its
+// functions must be set.
+type Domain[T any] struct {
+	// ParseLiteral converts the text of a numeric literal, such as 12 or 1.5e3, into a value
+	ParseLiteral func(text string) (T, error)
+	// Truthy decides whether a value is true when it is used as a condition
+	Truthy func(v T) bool
+	// FromBool is the value produced by comparisons and logical operators
+	FromBool func(b bool) T
+}
+
+// IntDomain computes with int.  Non-zero values are true and booleans are 1 and 0.
+func IntDomain() Domain[int] {
+	return Domain[int]{
+		ParseLiteral: func(text string) (int, error) {
+			v, err := strconv.Atoi(text)
+			if err != nil {
+				return 0, fmt.Errorf("invalid integer: %s", text)
+			}
+			return v, nil
+		},
+		Truthy: func(v int) bool { return v != 0 },
+		FromBool: func(b bool) int {
+			if b {
+				return 1
+			}
+			return 0
+		},
+	}
+}
+
+// Int64Domain computes with int64.  Non-zero values are true and booleans are 1 and 0.
+func Int64Domain() Domain[int64] {
+	return Domain[int64]{
+		ParseLiteral: func(text string) (int64, error) {
+			v, err := strconv.ParseInt(text, 10, 64)
+			if err != nil {
+				return 0, fmt.Errorf("invalid integer: %s", text)
+			}
+			return v, nil
+		},
+		Truthy: func(v int64) bool { return v != 0 },
+		FromBool: func(b bool) int64 {
+			if b {
+				return 1
+			}
+			return 0
+		},
+	}
+}
+
+// Float64Domain computes with float64.  Non-zero values are true and booleans are 1 and 0.
+func Float64Domain() Domain[float64] {
+	return Domain[float64]{
+		ParseLiteral: func(text string) (float64, error) {
+			v, err := strconv.ParseFloat(text, 64)
+			if err != nil {
+				return 0, fmt.Errorf("invalid float: %s", text)
+			}
+			return v, nil
+		},
+		Truthy: func(v float64) bool { return v != 0 },
+		FromBool: func(b bool) float64 {
+			if b {
+				return 1
+			}
+			return 0
+		},
+	}
+}
+
+// BigIntDomain computes with arbitrary precision integers.  Operators must not modify
+// their operands.  Non-zero values are true and booleans are 1 and 0.
+func BigIntDomain() Domain[*big.Int] {
+	return Domain[*big.Int]{
+		ParseLiteral: func(text string) (*big.Int, error) {
+			v, ok := new(big.Int).SetString(text, 10)
+			if !ok {
+				return nil, fmt.Errorf("invalid integer: %s", text)
+			}
+			return v, nil
+		},
+		Truthy: func(v *big.Int) bool { return v.Sign() != 0 },
+		FromBool: func(b bool) *big.Int {
+			if b {
+				return big.NewInt(1)
+			}
+			return big.NewInt(0)
+		},
+	}
+}
+
+// BigRatDomain computes with exact rational numbers.  Literals may be written as
+// decimals or in scientific notation.  Operators must not modify their operands.
+// Non-zero values are true and booleans are 1 and 0.
+func BigRatDomain() Domain[*big.Rat] {
+	return Domain[*big.Rat]{
+		ParseLiteral: func(text string) (*big.Rat, error) {
+			v, ok := new(big.Rat).SetString(text)
+			if !ok {
+				return nil, fmt.Errorf("invalid rational: %s", text)
+			}
+			return v, nil
+		},
+		Truthy: func(v *big.Rat) bool { return v.Sign() != 0 },
+		FromBool: func(b bool) *big.Rat {
+			if b {
+				return big.NewRat(1, 1)
+			}
+			return big.NewRat(0, 1)
+		},
+	}
+}
@@ -0,0 +1,82 @@
+package tok
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Float64Domain(t *testing.T) {
+	i := New(Float64Domain())
+	i.AddExpressionOp("+", func(a, b float64) float64 { return a + b })
+	i.AddFactorOp("/", func(a, b float64) float64 { return a / b })
+	i.AddComparisonOp("<", func(a, b float64) bool { return a < b })
+
+	v, err := i.Execute("1 / 4 + 1e-1")
+	assert.NoError(t, err)
+	assert.InDelta(t, 0.35, v, 1e-12)
+
+	v, err = i.Execute("if 0.5 < 1 then 2.5 else 0")
+	assert.NoError(t, err)
+	assert.Equal(t, 2.5, v)
+}
+
+func Test_Int64Domain(t *testing.T) {
+	i := New(Int64Domain())
+	i.AddFactorOp("*", func(a, b int64) int64 { return a * b })
+
+	v, err := i.Execute("4000000000 * 2")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(8000000000), v)
+
+	_, err = i.Execute("1.5")
+	assert.Error(t, err)
+}
+
+func Test_BigIntDomain(t *testing.T) {
+	i := New(BigIntDomain())
+	i.AddExpressionOp("-", func(a, b *big.Int) *big.Int { return new(big.Int).Sub(a, b) })
+	i.AddFactorOp("*", func(a, b *big.Int) *big.Int { return new(big.Int).Mul(a, b) })
+	i.AddComparisonOp("<", func(a, b *big.Int) bool { return a.Cmp(b) < 0 })
+
+	_, err := i.Execute("def fact n = if n < 2 then 1 else n * fact(n - 1)")
+	assert.NoError(t, err)
+
+	v, err := i.Execute("fact(30)")
+	assert.NoError(t, err)
+	assert.Equal(t, "265252859812191058636308480000000", v.String())
+
+	v, err = i.Execute("123456789012345678901234567890 * 10")
+	assert.NoError(t, err)
+	assert.Equal(t, "1234567890123456789012345678900", v.String())
+}
+
+func Test_BigRatDomain(t *testing.T) {
+	i := New(BigRatDomain())
+	i.AddExpressionOp("+", func(a, b *big.Rat) *big.Rat { return new(big.Rat).Add(a, b) })
+	i.AddFactorOp("/", func(a, b *big.Rat) *big.Rat { return new(big.Rat).Quo(a, b) })
+
+	v, err := i.Execute("1 / 3 + 0.5")
+	assert.NoError(t, err)
+	assert.Equal(t, "5/6", v.RatString())
+}
+
+func Test_CustomDomain(t *testing.T) {
+	// a domain of strings where literals are kept as text and + concatenates
+	i := New(Domain[string]{
+		ParseLiteral: func(text string) (string, error) { return text, nil },
+		Truthy:       func(v string) bool { return v != "" },
+		FromBool: func(b bool) string {
+			if b {
+				return "true"
+			}
+			return ""
+		},
+	})
+	i.AddExpressionOp("+", func(a, b string) string { return a + b })
+
+	v, err := i.Execute("12 + 3.5")
+	assert.NoError(t, err)
+	assert.Equal(t, "123.5", v)
+}
@@ -0,0 +1,161 @@
+package tok
+
+import (
+	"fmt"
+)
+
+// The errors returned by Execute, Parse and Eval are wrapped in a *PositionError which
+// records where in the source they were caused.  The types below describe what went
+// wrong and can be found with errors.As.
+
+// SyntaxError is returned when text does not fit the grammar of the Interpreter
+type SyntaxError struct {
+	Msg string
+}
+
+func (e *SyntaxError) Error() string {
+	return e.Msg
+}
+
+func syntaxErrorf(format string, args ...interface{}) error {
+	return &SyntaxError{Msg: fmt.Sprintf(format, args...)}
+}
+
+// UndefinedLabelError is returned when a label is used which has no value bound to it
+type UndefinedLabelError struct {
+	Name string
+}
+
+func (e *UndefinedLabelError) Error() string {
+	return fmt.Sprintf("could not find value for label: %s", e.Name)
+}
+
+// UndefinedFunctionError is returned when a function is called which has not been defined
+type UndefinedFunctionError struct {
+	Name string
+}
+
+func (e *UndefinedFunctionError) Error() string {
+	return fmt.Sprintf("undefined function: %s", e.Name)
+}
+
+// UndefinedOperatorError is returned when a node uses an operator which the Interpreter
+// does not have.  Nodes which were parsed by the same Interpreter never cause this.
+type UndefinedOperatorError struct {
+	Op string
+}
+
+func (e *UndefinedOperatorError) Error() string {
+	return fmt.Sprintf("undefined operator: %s", e.Op)
+}
+
+// ArityError is returned when a function is called with the wrong number of arguments
+type ArityError struct {
+	Function string
+	Expected int
+	Got      int
+}
+
+func (e *ArityError) Error() string {
+	return fmt.Sprintf("function %s expects %d arguments but got %d", e.Function, e.Expected, e.Got)
+}
+
+// CallDepthError is returned when function calls are nested more deeply than the
+// Interpreter allows, which is usually caused by a recursive function that never ends
+type CallDepthError struct {
+	Function string
+	Depth    int
+}
+
+func (e *CallDepthError) Error() string {
+	return fmt.Sprintf("maximum call depth of %d exceeded in %s", e.Depth, e.Function)
+}
+
+// OperatorError is returned when the implementation of an operator fails, either by
+// returning an error or by panicking
+type OperatorError struct {
+	Op  string
+	Err error
+}
+
+func (e *OperatorError) Error() string {
+	return fmt.Sprintf("operator %s: %v", e.Op, e.Err)
+}
+
+func (e *OperatorError) Unwrap() error {
+	return e.Err
+}
+
+// FunctionError is returned when a function registered from Go fails, either by
+// returning an error or by panicking
+type FunctionError struct {
+	Function string
+	Err      error
+}
+
+func (e *FunctionError) Error() string {
+	return fmt.Sprintf("function %s: %v", e.Function, e.Err)
+}
+
+func (e *FunctionError) Unwrap() error {
+	return e.Err
+}
+
+// OperatorConflictError is returned when an operator is added with a symbol that is
+// already used by an operator with a different precedence
+type OperatorConflictError struct {
+	Symbol             string
+	Precedence         int
+	ExistingPrecedence int
+}
+
+func (e *OperatorConflictError) Error() string {
+	return fmt.Sprintf("operator %s is already defined with precedence %d", e.Symbol, e.ExistingPrecedence)
+}
+
+// InvalidOperatorError is returned when an operator cannot be added because its symbol,
+// precedence or associativity is not allowed
+type InvalidOperatorError struct {
+	Symbol string
+	Reason string
+}
+
+func (e *InvalidOperatorError) Error() string {
+	return fmt.Sprintf("invalid operator %s: %s", e.Symbol, e.Reason)
+}
+
+// PanicError is returned when evaluation panics somewhere other than in an operator,
+// such as in a Domain
+type PanicError struct {
+	Value interface{}
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic during evaluation: %v", e.Value)
+}
+
+// recoverError converts a panic into a *PanicError stored in err.  It must be deferred.
+func recoverError(err *error) {
+	if r := recover(); r != nil {
+		*err = &PanicError{Value: r}
+	}
+}
+
+// FunctionValueError is returned when a function is used where a value from the domain
+// is needed, such as an operand of an operator or an argument of a function from Go
+type FunctionValueError struct {
+	Function string
+}
+
+func (e *FunctionValueError) Error() string {
+	return fmt.Sprintf("expected a value but got function %s", e.Function)
+}
+
+// NotAFunctionError is returned when an expression which is not a function is called
+type NotAFunctionError struct {
+	Expr string
+}
+
+func (e *NotAFunctionError) Error() string {
+	return fmt.Sprintf("%s is not a function", e.Expr)
+}
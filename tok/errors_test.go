@@ -0,0 +1,169 @@
+package tok
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_SyntaxError(t *testing.T) {
+	i := newTestInterpreter()
+	for _, input := range []string{"", "1 +", "(1", "1 $ 2", "def", "x = ", "if 1 then 2", "f(1 2)", "1.5", "1 < 2 < 3"} {
+		_, err := i.Execute(input)
+		var syntaxErr *SyntaxError
+		assert.True(t, errors.As(err, &syntaxErr), input)
+	}
+}
+
+func Test_UndefinedLabelError(t *testing.T) {
+	i := newTestInterpreter()
+	_, err := i.Execute("x + 1")
+	var labelErr *UndefinedLabelError
+	assert.True(t, errors.As(err, &labelErr))
+	assert.Equal(t, "x", labelErr.Name)
+
+	_, err = i.Execute("def f a = a + b")
+	assert.True(t, errors.As(err, &labelErr))
+	assert.Equal(t, "b", labelErr.Name)
+}
+
+func Test_UndefinedFunctionError(t *testing.T) {
+	i := newTestInterpreter()
+	_, err := i.Execute("g(1)")
+	var funcErr *UndefinedFunctionError
+	assert.True(t, errors.As(err, &funcErr))
+	assert.Equal(t, "g", funcErr.Name)
+
+	_, err = i.Execute("def f a = g(a)")
+	assert.True(t, errors.As(err, &funcErr))
+	assert.Equal(t, "g", funcErr.Name)
+}
+
+func Test_ArityError(t *testing.T) {
+	i := newTestInterpreter()
+	_, err := i.Execute("def f a b = a + b")
+	assert.NoError(t, err)
+
+	_, err = i.Execute("f(1)")
+	var arityErr *ArityError
+	assert.True(t, errors.As(err, &arityErr))
+	assert.Equal(t, ArityError{Function: "f", Expected: 2, Got: 1}, *arityErr)
+}
+
+func Test_CallDepthError(t *testing.T) {
+	i := newTestInterpreter()
+	_, err := i.Execute("def f a = f(a)")
+	assert.NoError(t, err)
+
+	_, err = i.Execute("f(1)")
+	var depthErr *CallDepthError
+	assert.True(t, errors.As(err, &depthErr))
+	assert.Equal(t, "f", depthErr.Function)
+}
+
+func Test_DeeplyNestedInput_IsError(t *testing.T) {
+	i := newTestInterpreter()
+	i.AddBinaryOp("^", 8, RightAssoc, func(a, b int) int { return a })
+	for _, input := range []string{
+		strings.Repeat("(", 600000) + "1" + strings.Repeat(")", 600000),
+		strings.Repeat("-", 600000) + "1",
+		strings.Repeat("not ", 600000) + "1",
+		strings.Repeat("1 ^ ", 600000) + "1",
+		strings.Repeat("f(", 600000) + "1" + strings.Repeat(")", 600000),
+		strings.Repeat("1+", 1000000) + "1",
+		strings.Repeat("1 < 2 and ", 300000) + "1",
+	} {
+		_, err := i.Execute(input)
+		var syntaxErr *SyntaxError
+		assert.True(t, errors.As(err, &syntaxErr), input[:8])
+		var pe *PositionError
+		assert.True(t, errors.As(err, &pe), input[:8])
+		assert.Equal(t, "expression is nested too deeply", syntaxErr.Msg)
+
+		_, diagnostics := i.ParseProgram(input)
+		assert.NotEmpty(t, diagnostics, input[:8])
+	}
+
+	// nesting which is deep but not too deep is evaluated
+	v, err := i.Execute(strings.Repeat("(", 500) + "1" + strings.Repeat(")", 500) + strings.Repeat(" ^ 1", 500))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, v)
+	v, err = i.Execute(strings.Repeat("-", 500) + "1")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, v)
+	v, err = i.Execute(strings.Repeat("1+", 1000) + "1")
+	assert.NoError(t, err)
+	assert.Equal(t, 1001, v)
+}
+
+func Test_OperatorConflictError(t *testing.T) {
+	i := newTestInterpreter()
+	err := i.AddFactorOp("+", func(a, b int) int { return a + b })
+	var conflictErr *OperatorConflictError
+	assert.True(t, errors.As(err, &conflictErr))
+	assert.Equal(t, OperatorConflictError{Symbol: "+", Precedence: FactorPrecedence, ExistingPrecedence: ExpressionPrecedence}, *conflictErr)
+}
+
+func Test_InvalidOperatorError(t *testing.T) {
+	i := newTestInterpreter()
+	var invalidErr *InvalidOperatorError
+	assert.True(t, errors.As(i.AddUnaryOp("=", func(a int) int { return a }), &invalidErr))
+	assert.True(t, errors.As(i.AddBinaryOp("@", -1, LeftAssoc, func(a, b int) int { return a }), &invalidErr))
+	assert.True(t, errors.As(i.AddFactorOp("@", func(a, b int) int { return a }, LeftAssoc, RightAssoc), &invalidErr))
+}
+
+func Test_OperatorPanicIsOperatorError(t *testing.T) {
+	i := newTestInterpreter()
+	_, err := i.Execute("1 + 1 / 0")
+	var opErr *OperatorError
+	assert.True(t, errors.As(err, &opErr))
+	assert.Equal(t, "/", opErr.Op)
+	assert.Equal(t, Span{Start: Position{Line: 1, Column: 5, Offset: 4}, End: Position{Line: 1, Column: 10, Offset: 9}}, errorSpan(t, err))
+}
+
+func Test_OperatorReturningErrorIsOperatorError(t *testing.T) {
+	i := NewNumberInterpreter()
+	AddNumberOp(&i, "%", FactorPrecedence, LeftAssoc, func(a, b int) int { return a % b }, nil)
+	_, err := i.Execute("1.5 % 2")
+	var opErr *OperatorError
+	assert.True(t, errors.As(err, &opErr))
+	assert.Equal(t, "%", opErr.Op)
+}
+
+func Test_DomainPanicIsPanicError(t *testing.T) {
+	domain := IntDomain()
+	domain.Truthy = func(v int) bool { panic("no truth here") }
+	i := New(domain)
+
+	_, err := i.Execute("if 1 then 2 else 3")
+	var panicErr *PanicError
+	assert.True(t, errors.As(err, &panicErr))
+	assert.Equal(t, "no truth here", panicErr.Value)
+}
+
+func Test_MalformedInputDoesNotPanic(t *testing.T) {
+	i := newTestInterpreter()
+	for _, input := range []string{
+		"x =", "= 1", "def f", "def = 1", "def f x y", "f(", "f(,", "f(1,", ")", "(", "()", ",",
+		"if", "if 1", "if 1 then", "if 1 then 2 else", "not", "1 and", "- -", "x = = 1", "def if = 1",
+		"1e", "1.", ".5", "\n\n\t", "é", "then", "1 + (2 * (3", "def f x = x\nf(1)",
+	} {
+		assert.NotPanics(t, func() { i.Execute(input) }, input)
+		assert.NotPanics(t, func() { i.ExecuteProgram(input) }, input)
+	}
+}
+
+func FuzzExecute(f *testing.F) {
+	for _, seed := range []string{"1 + 2", "def f x = if x < 1 then 0 else f(x - 1)", "f(3)", "x = -(1 / 0)", "not 1 and 2"} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, input string) {
+		i := newTestInterpreter()
+		i.Execute("def f x = if x < 1 then 0 else f(x - 1)")
+		i.Execute(input)
+		i.ExecuteProgram(input)
+		i.ParseProgram(input)
+	})
+}
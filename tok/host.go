@@ -0,0 +1,117 @@
+package tok
+
+import (
+	"fmt"
+	"sort"
+	"unicode"
+)
+
+// RegisterFunc makes the Go function fn callable from programs by name.  It accepts
+// any number of arguments.  An error returned by fn, or a panic, stops evaluation and
+// is returned from Execute as a *FunctionError.  A function registered with the same
+// name as an existing function replaces it.
+func (i *Interpreter[T]) RegisterFunc(name string, fn func(args ...T) (T, error)) error {
+	return i.registerHost(name, -1, func(args []T) (T, error) {
+		return fn(args...)
+	})
+}
+
+// RegisterFunc0 registers a Go function which takes no arguments as with RegisterFunc
+func (i *Interpreter[T]) RegisterFunc0(name string, fn func() (T, error)) error {
+	return i.registerHost(name, 0, func(args []T) (T, error) {
+		return fn()
+	})
+}
+
+// RegisterFunc1 registers a Go function which takes one argument as with RegisterFunc
+func (i *Interpreter[T]) RegisterFunc1(name string, fn func(a T) (T, error)) error {
+	return i.registerHost(name, 1, func(args []T) (T, error) {
+		return fn(args[0])
+	})
+}
+
+// RegisterFunc2 registers a Go function which takes two arguments as with RegisterFunc
+func (i *Interpreter[T]) RegisterFunc2(name string, fn func(a, b T) (T, error)) error {
+	return i.registerHost(name, 2, func(args []T) (T, error) {
+		return fn(args[0], args[1])
+	})
+}
+
+// RegisterFunc3 registers a Go function which takes three arguments as with RegisterFunc
+func (i *Interpreter[T]) RegisterFunc3(name string, fn func(a, b, c T) (T, error)) error {
+	return i.registerHost(name, 3, func(args []T) (T, error) {
+		return fn(args[0], args[1], args[2])
+	})
+}
+
+// HostFunctions returns the names of the functions registered from Go in sorted order
+func (i *Interpreter[T]) HostFunctions() []string {
+	names := make([]string, 0)
+	for name, f := range i.funcBindings {
+		if f.host != nil {
+			names = append(names, name)
+		}
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+func (i *Interpreter[T]) registerHost(name string, arity int, host func(args []T) (T, error)) error {
+	if err := checkFunctionName(name); err != nil {
+		return err
+	}
+
+	i.funcBindings[name] = &function[T]{
+		name:  name,
+		host:  host,
+		arity: arity,
+	}
+	i.refresh()
+	return nil
+}
+
+// checkFunctionName makes sure that name can be written as a label in a program
+func checkFunctionName(name string) error {
+	if name == "" {
+		return fmt.Errorf("invalid function name: %q", name)
+	}
+	// like a label the name must start with a letter which is followed by letters and digits
+	for n, c := range name {
+		if !unicode.IsLetter(c) && (n == 0 || !unicode.IsDigit(c)) {
+			return fmt.Errorf("invalid function name: %q", name)
+		}
+	}
+	if _, ok := keywords[name]; ok {
+		return fmt.Errorf("invalid function name: %s is a keyword", name)
+	}
+	return nil
+}
+
+// applyHost checks the number of arguments and calls a function registered from Go.
+// Functions from Go only take values from the domain so a function argument is an error.
+func (f *function[T]) applyHost(params []value[T]) (result value[T], err error) {
+	if f.arity >= 0 && len(params) != f.arity {
+		return result, &ArityError{Function: f.name, Expected: f.arity, Got: len(params)}
+	}
+
+	args := make([]T, len(params))
+	for a, p := range params {
+		if p.fn != nil {
+			return result, &FunctionError{Function: f.name, Err: &FunctionValueError{Function: p.fn.String()}}
+		}
+		args[a] = p.v
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = &FunctionError{Function: f.name, Err: fmt.Errorf("%v", r)}
+		}
+	}()
+
+	v, err := f.host(args)
+	if err != nil {
+		return result, &FunctionError{Function: f.name, Err: err}
+	}
+	return value[T]{v: v}, nil
+}
@@ -0,0 +1,100 @@
+package tok
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_RegisterFunc(t *testing.T) {
+	i := newTestInterpreter()
+	for input, expected := range map[string]int{
+		"max(3, 9, 4)":           9,
+		"max(7)":                 7,
+		"abs(sub(2, 5)) * 2":     6,
+		"answer() + 1":           43,
+		"max(abs(sub(1, 9)), 2)": 8,
+	} {
+		v, err := i.Execute(input)
+		assert.NoError(t, err, input)
+		assert.Equal(t, expected, v, input)
+	}
+}
+
+func Test_HostFunctionsCanBeCalledFromDefinitions(t *testing.T) {
+	i := newTestInterpreter()
+	_, err := i.Execute("def dist a b = abs(sub(a, b))")
+	assert.NoError(t, err)
+
+	v, err := i.Execute("dist(3, 10)")
+	assert.NoError(t, err)
+	assert.Equal(t, 7, v)
+}
+
+func Test_HostFunctionArity_IsError(t *testing.T) {
+	i := newTestInterpreter()
+	for input, expected := range map[string]ArityError{
+		"abs(1, 2)": {Function: "abs", Expected: 1, Got: 2},
+		"sub(1)":    {Function: "sub", Expected: 2, Got: 1},
+		"answer(1)": {Function: "answer", Expected: 0, Got: 1},
+	} {
+		_, err := i.Execute(input)
+		var arityErr *ArityError
+		assert.True(t, errors.As(err, &arityErr), input)
+		assert.Equal(t, expected, *arityErr, input)
+	}
+}
+
+func Test_HostFunctionError_IsError(t *testing.T) {
+	i := newTestInterpreter()
+	lookupErr := errors.New("no such rate")
+	i.RegisterFunc1("lookupRate", func(id int) (int, error) {
+		if id != 1 {
+			return 0, lookupErr
+		}
+		return 5, nil
+	})
+	i.RegisterFunc1("boom", func(a int) (int, error) { panic("boom") })
+
+	v, err := i.Execute("lookupRate(1) * 2")
+	assert.NoError(t, err)
+	assert.Equal(t, 10, v)
+
+	_, err = i.Execute("1 + lookupRate(2)")
+	assert.True(t, errors.Is(err, lookupErr))
+	var funcErr *FunctionError
+	assert.True(t, errors.As(err, &funcErr))
+	assert.Equal(t, "lookupRate", funcErr.Function)
+	assert.Equal(t, Position{Line: 1, Column: 5, Offset: 4}, errorSpan(t, err).Start)
+
+	_, err = i.Execute("max()")
+	assert.True(t, errors.As(err, &funcErr))
+
+	_, err = i.Execute("boom(1)")
+	assert.True(t, errors.As(err, &funcErr))
+	assert.Equal(t, "boom", funcErr.Function)
+}
+
+func Test_RegisterFuncInvalidName_IsError(t *testing.T) {
+	i := NewInterpreter()
+	fn := func(a int) (int, error) { return a, nil }
+	for _, name := range []string{"", "1f", "f-g", "if", "not"} {
+		assert.Error(t, i.RegisterFunc1(name, fn), name)
+	}
+	assert.NoError(t, i.RegisterFunc1("f2", fn))
+}
+
+func Test_HostFunctionsSurviveReset(t *testing.T) {
+	i := newTestInterpreter()
+	_, err := i.Execute("def double a = a * 2")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"abs", "answer", "max", "sub"}, i.HostFunctions())
+	assert.Len(t, i.Functions(), 1)
+
+	i.Reset()
+	assert.Empty(t, i.Functions())
+	v, err := i.Execute("abs(0 + sub(0, 3))")
+	assert.NoError(t, err)
+	assert.Equal(t, 3, v)
+}
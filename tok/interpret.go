@@ -2,7 +2,7 @@ package tok
 
 import (
 	"fmt"
-	"strconv"
+	"sort"
 )
 
 /*
@@ -10,159 +10,500 @@ BNF
 Statement := Assignment | Expression | FuncDef
 FuncDef := Label(def) Label+ AssignOp Expression
 Assignment := Label AssignOp Expression
-Expression := Factor[ExpOp Expression]
-Factor := Term [FactorOp Factor]
-Term := Integer | Label | UnaryOp Term | LParen Expression RParen | Label LParen [Label[,Label]*] RParen
-Integer := Digit+
+Expression := Operand [(BinaryOp | Label(and) | Label(or)) Operand]*
+Operand := Label(not) Expression | Term
+Term := Literal | Label | UnaryOp Term | LParen Expression RParen | Label LParen [Label[,Label]*] RParen | Conditional
+Conditional := Label(if) Expression Label(then) Expression Label(else) Expression
+Literal := Digit+ [. Digit+] [(e|E) [+|-] Digit+]
 Label := Alpha[Alpha|Digit]+
 */
 
 // Interpreter allows a user to define a set of operators that fit within the following grammar
 // and then use the interpreter to compute the results of programs written in that language.
+// Binary operators are grouped according to their precedence and associativity.
+//
+// The values the interpreter computes with are of type T and are described by a Domain.
 //
 // Grammar:
 //
-// - Expression := Factor[ExpOp Expression]
+// - Expression := Operand [(BinaryOp | and | or) Operand]*
+//
+// - Operand := not Expression | Term
+//
+// - Term := Literal | UnaryOp Term | Label | Callee Arguments* | Conditional | Lambda | Let
 //
-// - Factor := Term [FactorOp Factor]
+// - Callee := LParen Expression RParen | Label Arguments
 //
-// - Term := Integer | UnaryOp Term | LParen Expression RParen | Label LParen RParen
+// - Arguments := LParen [Expression [, Expression]*] RParen
 //
-// - Integer := Digit+
+// - Conditional := if Expression then Expression else Expression
 //
-type Interpreter struct {
-	expOps        map[string]BinaryOperator
-	factorOps     map[string]BinaryOperator
-	unaryOps      map[string]UnaryOperator
-	labelBindings map[string]int
-	funcBindings  map[string]function
+// - Lambda := (\ | fun) Label* -> Expression
+//
+// - Let := let Label = Expression [, Label = Expression]* in Expression
+//
+// - Literal := Digit+ [. Digit+] [(e|E) [+|-] Digit+]
+type Interpreter[T any] struct {
+	binaryOps     map[string]binaryOp[T]
+	unaryOps      map[string]unaryOp[T]
+	labelBindings map[string]value[T]
+	funcBindings  map[string]*function[T]
+	domain        Domain[T]
+	depth         int
+
+	// tokenizer recognizes the operators of the Interpreter.  It is rebuilt whenever an
+	// operator is added rather than for every statement.
+	tokenizer tokenizer
+
+	// outer holds the bindings which enclose labelBindings, such as those captured by
+	// the closure which is being called.  It is nil at the top level.
+	outer *scope[T]
+
+	// walk disables the compiler so that every node is evaluated by walking the tree
+	walk bool
+
+	// optimize enables the optimizer.  generation counts the changes to the operators
+	// and functions so that what was optimized for them can be compiled again.
+	optimize   bool
+	generation int
+
+	// diagnostics collects the syntax errors which the parser recovered from.  It is
+	// only set while parsing with ParseProgram.
+	diagnostics *[]*PositionError
+
+	// nesting is how deeply the parser has recursed into the statement it is parsing.
+	// It is only counted in the copy of the Interpreter which parses a statement.
+	nesting int
 }
 
-// BinaryOperator is a function which takes two integers and returns one
-type BinaryOperator func(a, b int) int
+// IntInterpreter is an Interpreter which computes with integers
+type IntInterpreter = Interpreter[int]
+
+// maxCallDepth is the deepest that function calls may be nested before evaluation
+// is aborted.  It stops runaway recursion from exhausting the stack.
+const maxCallDepth = 10000
+
+// maxNesting is how deeply the parser may recurse into nested parentheses, operators and
+// terms.  It stops deeply nested input from exhausting the stack.
+const maxNesting = 2000
+
+// BinaryOperator is a function which takes two values and returns one
+type BinaryOperator[T any] func(a, b T) T
+
+// UnaryOperator is a function which takes one value and returns one
+type UnaryOperator[T any] func(a T) T
+
+// Associativity determines how a chain of binary operators from the same
+// level of precedence is grouped.
+type Associativity int
+
+const (
+	// LeftAssoc groups operators from the left: a - b - c is (a - b) - c
+	LeftAssoc Associativity = iota
+	// RightAssoc groups operators from the right: a ^ b ^ c is a ^ (b ^ c)
+	RightAssoc Associativity = iota
+	// NonAssoc operators cannot be chained: a < b < c is an error
+	NonAssoc Associativity = iota
+)
+
+func (a Associativity) String() string {
+	switch a {
+	case LeftAssoc:
+		return "left"
+	case RightAssoc:
+		return "right"
+	case NonAssoc:
+		return "non"
+	default:
+		return fmt.Sprintf("Associativity(%d)", int(a))
+	}
+}
+
+const (
+	// OrPrecedence is the precedence of the `or` keyword
+	OrPrecedence = 1
+	// AndPrecedence is the precedence of the `and` keyword
+	AndPrecedence = 2
+	// NotPrecedence is the precedence of the `not` keyword; its operand includes any
+	// operator with a higher precedence
+	NotPrecedence = 3
+	// ComparisonPrecedence is the precedence of operators added with AddComparisonOp
+	ComparisonPrecedence = 5
+	// ExpressionPrecedence is the precedence of operators added with AddExpressionOp
+	ExpressionPrecedence = 10
+	// FactorPrecedence is the precedence of operators added with AddFactorOp
+	FactorPrecedence = 20
+)
+
+// opInfo is the grammatical information about a binary operator which the parser needs
+type opInfo struct {
+	precedence int
+	assoc      Associativity
+}
+
+type binaryOp[T any] struct {
+	opInfo
+	apply func(a, b T) (T, error)
+	pure  bool
+}
 
-// UnaryOperator is a function which takes one integer and returns one
-type UnaryOperator func(a int) int
+type unaryOp[T any] struct {
+	apply func(a T) (T, error)
+	pure  bool
+}
 
-type function struct {
-	tokens     []token
+type function[T any] struct {
+	body       Node
 	parameters []string
 	name       string
-	expOps     map[string]BinaryOperator
-	factorOps  map[string]BinaryOperator
-	unaryOps   map[string]UnaryOperator
+
+	// host is the implementation of a function registered from Go; such a function has
+	// no body.  It takes arity arguments or, if arity is negative, any number of them.
+	host  func(args []T) (T, error)
+	arity int
+
+	// code is the compiled body, or nil if the body could not be compiled or the
+	// Interpreter only walks the tree
+	code *chunk[T]
+
+	// value is the function taken as a value when other functions were inlined into
+	// code.  A value may be called after those functions are redefined, so like a
+	// closure its body calls them instead.
+	value *function[T]
 }
 
-func (f *function) apply(params []int) (int, error) {
-	interpreter := NewInterpreter()
-	interpreter.expOps = f.expOps
-	interpreter.factorOps = f.factorOps
-	interpreter.unaryOps = f.unaryOps
+func (f *function[T]) String() string {
+	return f.name
+}
 
+// asValue is f as it is when taken as a value, such as by h = f
+func (f *function[T]) asValue() *function[T] {
+	if f.value != nil {
+		return f.value
+	}
+	return f
+}
+
+// apply evaluates the function.  A compiled body runs in a frame on the stack of the
+// vm; otherwise the body is walked in a new scope which shares the operators and
+// functions of the caller, so that the body may call other functions or itself.
+func (f *function[T]) apply(caller *Interpreter[T], params []value[T]) (value[T], error) {
+	if f.host != nil {
+		return f.applyHost(params)
+	}
 	if len(params) != len(f.parameters) {
-		return 0, fmt.Errorf("missing parameters; expected %d got %d", len(f.parameters), len(params))
+		return value[T]{}, &ArityError{Function: f.name, Expected: len(f.parameters), Got: len(params)}
+	}
+	if caller.depth >= maxCallDepth {
+		return value[T]{}, &CallDepthError{Function: f.name, Depth: maxCallDepth}
+	}
+	if f.code != nil {
+		return f.applyCompiled(caller, params)
 	}
 
+	interpreter := *caller
+	interpreter.labelBindings = make(map[string]value[T], len(params))
+	interpreter.outer = nil
+	interpreter.depth++
+
 	// bind the parameter labels to their given values
 	for i, label := range f.parameters {
 		interpreter.labelBindings[label] = params[i]
 	}
 
-	return interpreter.executeTokens(f.tokens)
+	return interpreter.evalNode(f.body)
+}
+
+// NewInterpreter configures a new Interpreter which computes with integers and returns it
+func NewInterpreter() IntInterpreter {
+	return New(IntDomain())
+}
+
+// New configures a new Interpreter which computes with values from the given Domain
+func New[T any](domain Domain[T]) Interpreter[T] {
+	return Interpreter[T]{
+		binaryOps:     make(map[string]binaryOp[T]),
+		unaryOps:      make(map[string]unaryOp[T]),
+		labelBindings: make(map[string]value[T]),
+		funcBindings:  make(map[string]*function[T]),
+		domain:        domain,
+		optimize:      true,
+		tokenizer:     newTokenizer(nil),
+	}
+}
+
+// AddBinaryOp will add a binary operator with the given precedence and associativity.
+// Operators with a higher precedence bind more tightly than those with a lower precedence
+// and precedence must not be negative.  If an operator already exists with the given
+// symbol and precedence it will be replaced.  If an operator with this symbol exists at a
+// different precedence then this will fail.
+func (i *Interpreter[T]) AddBinaryOp(symbol string, precedence int, assoc Associativity, apply BinaryOperator[T]) error {
+	return i.addBinaryOp(symbol, precedence, assoc, func(a, b T) (T, error) {
+		return apply(a, b), nil
+	})
 }
 
-// NewInterpreter configures a new Interpreter object and returns it
-func NewInterpreter() Interpreter {
-	return Interpreter{
-		expOps:        make(map[string]BinaryOperator),
-		factorOps:     make(map[string]BinaryOperator),
-		unaryOps:      make(map[string]UnaryOperator),
-		labelBindings: make(map[string]int),
-		funcBindings:  make(map[string]function),
+func (i *Interpreter[T]) addBinaryOp(symbol string, precedence int, assoc Associativity, apply func(a, b T) (T, error)) error {
+	if err := checkOperatorSymbol(symbol); err != nil {
+		return err
+	}
+	if precedence < 0 {
+		return &InvalidOperatorError{Symbol: symbol, Reason: fmt.Sprintf("precedence must not be negative: %d", precedence)}
+	}
+	if assoc < LeftAssoc || assoc > NonAssoc {
+		return &InvalidOperatorError{Symbol: symbol, Reason: fmt.Sprintf("invalid associativity: %d", assoc)}
+	}
+	if op, ok := i.binaryOps[symbol]; ok && op.precedence != precedence {
+		return &OperatorConflictError{Symbol: symbol, Precedence: precedence, ExistingPrecedence: op.precedence}
+	}
+
+	i.binaryOps[symbol] = binaryOp[T]{
+		opInfo: opInfo{precedence: precedence, assoc: assoc},
+		apply:  apply,
 	}
+	i.tokenizer = i.createTokenizer()
+	i.refresh()
+	return nil
 }
 
 // AddExpressionOp will add a new expression level operator for the interpreter to use.
 // If an operator already exists at the expression level with the given symbol, it will
 // be replaced.  If an operator with this symbol exists in the Factor operator set
 // then this will fail.
-func (i *Interpreter) AddExpressionOp(symbol string, apply BinaryOperator) error {
-	// make sure the operator does not exist in the Factor set
-	if _, ok := i.factorOps[symbol]; ok {
-		return fmt.Errorf("attempting to add operator to expression set when it is already in factor set")
+//
+// Operators are left associative unless an Associativity is given.
+func (i *Interpreter[T]) AddExpressionOp(symbol string, apply BinaryOperator[T], assoc ...Associativity) error {
+	a, err := optionalAssociativity(symbol, assoc)
+	if err != nil {
+		return err
 	}
-	i.expOps[symbol] = apply
-	return nil
+	return i.AddBinaryOp(symbol, ExpressionPrecedence, a, apply)
 }
 
 // AddFactorOp will add a new operator with the given symbol to the Factor level
 // of interpretation.  If an operator already exists with this symbol for Factor level
 // it will be replaced.  If an operator with this symbol exists in the Expression operator
 // set then this will fail.
-func (i *Interpreter) AddFactorOp(symbol string, apply BinaryOperator) error {
-	// make sure the operator does not exist in the Expression set
-	if _, ok := i.expOps[symbol]; ok {
-		return fmt.Errorf("attempting to add operator to factor set when it is already in expression set")
+//
+// Operators are left associative unless an Associativity is given.
+func (i *Interpreter[T]) AddFactorOp(symbol string, apply BinaryOperator[T], assoc ...Associativity) error {
+	a, err := optionalAssociativity(symbol, assoc)
+	if err != nil {
+		return err
+	}
+	return i.AddBinaryOp(symbol, FactorPrecedence, a, apply)
+}
+
+// AddComparisonOp will add a non-associative binary operator at ComparisonPrecedence which
+// evaluates to the Domain's value for true when compare is true and false otherwise.  The
+// symbol may contain '=' so long as it is not exactly '=', which is reserved for assignment.
+func (i *Interpreter[T]) AddComparisonOp(symbol string, compare func(a, b T) bool) error {
+	return i.AddBinaryOp(symbol, ComparisonPrecedence, NonAssoc, func(a, b T) T {
+		return i.domain.FromBool(compare(a, b))
+	})
+}
+
+func checkOperatorSymbol(symbol string) error {
+	if symbol == "" {
+		return &InvalidOperatorError{Symbol: symbol, Reason: "symbol must not be empty"}
+	}
+	if symbol == "=" {
+		return &InvalidOperatorError{Symbol: symbol, Reason: "'=' is reserved for assignment"}
 	}
-	i.factorOps[symbol] = apply
 	return nil
 }
 
+func optionalAssociativity(symbol string, assoc []Associativity) (Associativity, error) {
+	if len(assoc) > 1 {
+		return LeftAssoc, &InvalidOperatorError{Symbol: symbol, Reason: fmt.Sprintf("expected at most one associativity got %d", len(assoc))}
+	}
+	if len(assoc) == 1 {
+		return assoc[0], nil
+	}
+	return LeftAssoc, nil
+}
+
 // AddUnaryOp will add a unary operator that will be applied at the Term level
 // of the language.
-func (i *Interpreter) AddUnaryOp(symbol string, apply UnaryOperator) error {
-	i.unaryOps[symbol] = apply
+func (i *Interpreter[T]) AddUnaryOp(symbol string, apply UnaryOperator[T]) error {
+	return i.addUnaryOp(symbol, func(a T) (T, error) {
+		return apply(a), nil
+	})
+}
+
+func (i *Interpreter[T]) addUnaryOp(symbol string, apply func(a T) (T, error)) error {
+	if err := checkOperatorSymbol(symbol); err != nil {
+		return err
+	}
+	i.unaryOps[symbol] = unaryOp[T]{apply: apply}
+	i.tokenizer = i.createTokenizer()
+	i.refresh()
 	return nil
 }
 
-// Execute will take a program that uses the interpreters defined language
-// and attempt to compute it's result
-func (i *Interpreter) Execute(text string) (int, error) {
-	// construct a tokenizer
-	tokenizer := i.createTokenizer()
+// SetTruthiness replaces the rule used to decide whether the condition of an
+// if expression is true.  By default the Domain's rule is used.
+func (i *Interpreter[T]) SetTruthiness(truthy func(v T) bool) {
+	i.domain.Truthy = truthy
+	i.refresh()
+}
 
-	tokens, err := tokenizer.tokenize(text)
+// OperatorInfo describes an operator which has been added to an Interpreter.  The
+// precedence and associativity of a unary operator are always zero.
+type OperatorInfo struct {
+	Symbol     string
+	Unary      bool
+	Precedence int
+	Assoc      Associativity
+	Pure       bool
+}
 
-	if err != nil {
-		return 0, err
+// Operators lists the operators of the Interpreter with the binary operators first,
+// from the lowest precedence to the highest, followed by the unary operators.
+func (i *Interpreter[T]) Operators() []OperatorInfo {
+	ops := make([]OperatorInfo, 0, len(i.binaryOps)+len(i.unaryOps))
+	for symbol, op := range i.binaryOps {
+		ops = append(ops, OperatorInfo{Symbol: symbol, Precedence: op.precedence, Assoc: op.assoc, Pure: op.pure})
+	}
+	for symbol, op := range i.unaryOps {
+		ops = append(ops, OperatorInfo{Symbol: symbol, Unary: true, Pure: op.pure})
 	}
 
-	return i.executeTokens(tokens)
+	sort.Slice(ops, func(a, b int) bool {
+		if ops[a].Unary != ops[b].Unary {
+			return !ops[a].Unary
+		}
+		if ops[a].Precedence != ops[b].Precedence {
+			return ops[a].Precedence < ops[b].Precedence
+		}
+		return ops[a].Symbol < ops[b].Symbol
+	})
+	return ops
 }
 
-func (i *Interpreter) executeTokens(tokens []token) (int, error) {
-	var pos int
-	var result int
-	if len(tokens) >= 3 && tokens[0].ty == labelType && tokens[1].ty == assignmentOpType {
-		result, pos = i.assignment(tokens, 0)
-	} else if tokens[0].ty == labelType && tokens[0].value == "def" {
-		var f function
-		var err error
-		f, pos, err = i.functionDef(tokens, 0)
-		if err != nil {
-			return 0, err
+// Variables returns a copy of the values which are bound to labels.  Labels which are
+// bound to functions are left out.
+func (i *Interpreter[T]) Variables() map[string]T {
+	vars := make(map[string]T, len(i.labelBindings))
+	for label, v := range i.labelBindings {
+		if v.fn == nil {
+			vars[label] = v.v
 		}
-		i.funcBindings[f.name] = f
-	} else {
-		var err error
-		result, pos, err = i.expression(tokens, 0)
-		if err != nil {
-			return 0, err
+	}
+	return vars
+}
+
+// Functions returns the definitions of the functions of the Interpreter sorted by name.
+// Functions which were registered from Go are listed by HostFunctions instead.
+func (i *Interpreter[T]) Functions() []FuncDef {
+	defs := make([]FuncDef, 0, len(i.funcBindings))
+	for _, f := range i.funcBindings {
+		if f.host == nil {
+			defs = append(defs, FuncDef{Name: f.name, Params: f.parameters, Body: f.body})
 		}
 	}
-	if pos != len(tokens) {
-		return 0, fmt.Errorf("unexpected tokens in expression: %s", tokens[pos].value)
+
+	sort.Slice(defs, func(a, b int) bool { return defs[a].Name < defs[b].Name })
+	return defs
+}
+
+// Reset removes every variable and function while keeping the operators and the
+// functions which were registered from Go
+func (i *Interpreter[T]) Reset() {
+	i.labelBindings = make(map[string]value[T])
+	for name, f := range i.funcBindings {
+		if f.host == nil {
+			delete(i.funcBindings, name)
+		}
 	}
-	return result, nil
+	i.refresh()
 }
 
-func (i *Interpreter) createTokenizer() tokenizer {
-	// build a list of the operators in this interpreter
-	opsList := make([]string, 0, len(i.expOps)+len(i.factorOps))
-	for k := range i.expOps {
-		opsList = append(opsList, k)
+// Execute will take a program that uses the interpreters defined language
+// and attempt to compute it's result.  Execute never panics: a panic while
+// evaluating is returned as an error.
+func (i *Interpreter[T]) Execute(text string) (T, error) {
+	node, err := i.Parse(text)
+	if err != nil {
+		var zero T
+		return zero, err
 	}
-	for k := range i.factorOps {
+
+	return i.Eval(node)
+}
+
+// Eval computes the result of a parsed statement.  Assignments and function
+// definitions update the state of the Interpreter.  An error reports the position
+// of the node which caused it.  A statement whose result is a function, such as an
+// assignment of a lambda, returns the zero value.
+//
+// A statement is evaluated by walking the tree, since it only runs once; the functions
+// it calls are optimized and compiled.  Use Compile for a statement which will run many
+// times.
+func (i *Interpreter[T]) Eval(node Node) (v T, err error) {
+	defer recoverError(&err)
+	result, err := i.evalNode(node)
+	if result.fn != nil {
+		return v, err
+	}
+	return result.v, err
+}
+
+// evalNode evaluates node and attaches the position of node to any error which
+// does not already have one
+func (i *Interpreter[T]) evalNode(node Node) (value[T], error) {
+	if node == nil {
+		return value[T]{}, fmt.Errorf("unknown node: %v", node)
+	}
+
+	v, err := i.eval(node)
+	return v, errorAt(node.Pos(), err)
+}
+
+func (i *Interpreter[T]) eval(node Node) (value[T], error) {
+	switch n := node.(type) {
+	case Literal:
+		return scalar(i.domain.ParseLiteral(n.Text))
+	case constant[T]:
+		return value[T]{v: n.v}, nil
+	case Label:
+		return i.lookupLabel(n.Name)
+	case UnaryOp:
+		return scalar(i.evalUnaryOp(n))
+	case BinaryOp:
+		return scalar(i.evalBinaryOp(n))
+	case Call:
+		return i.callFunction(n)
+	case Apply:
+		return i.evalApply(n)
+	case Lambda:
+		// a lambda which compiles is called in a frame rather than by walking its body
+		env := &scope[T]{vars: i.labelBindings, parent: i.outer}
+		if code := i.compileLambda(n); code != nil {
+			return value[T]{fn: &compiledClosure[T]{lambda: n, code: code, env: env}}, nil
+		}
+		return value[T]{fn: &closure[T]{lambda: n, env: env}}, nil
+	case Conditional:
+		return i.evalConditional(n)
+	case Let:
+		return i.evalLet(n)
+	case LogicalOp:
+		return scalar(i.evalLogicalOp(n))
+	case Not:
+		return scalar(i.evalNot(n))
+	case Assignment:
+		return i.assign(n)
+	case FuncDef:
+		return value[T]{}, i.defineFunction(n)
+	default:
+		return value[T]{}, fmt.Errorf("unknown node: %v", node)
+	}
+}
+
+func (i *Interpreter[T]) createTokenizer() tokenizer {
+	// build a list of the operators in this interpreter
+	opsList := make([]string, 0, len(i.binaryOps)+len(i.unaryOps))
+	for k := range i.binaryOps {
 		opsList = append(opsList, k)
 	}
 	for k := range i.unaryOps {
@@ -172,221 +513,277 @@ func (i *Interpreter) createTokenizer() tokenizer {
 	return newTokenizer(opsList)
 }
 
-func (i *Interpreter) functionDef(tokens []token, currentPos int) (f function, pos int, err error) {
-	if tokens[currentPos].ty != labelType || tokens[currentPos].value != "def" {
-		panic("unexpected token")
+func (i *Interpreter[T]) defineFunction(def FuncDef) error {
+	err := i.checkFunctionCorrectness(def.Name, def.Params, def.Body)
+	if err != nil {
+		return err
 	}
-	currentPos++
 
-	if tokens[currentPos].ty != labelType {
-		panic("expected label token found " + tokens[currentPos].value)
+	i.funcBindings[def.Name] = &function[T]{
+		name:       def.Name,
+		body:       def.Body,
+		parameters: def.Params,
 	}
-	funcName := tokens[currentPos].value
-	currentPos++
+	i.refresh()
+	return nil
+}
 
-	// each label from now until an assignment operator is encountered is a function parameter
-	parameters := make([]string, 0)
-	for ; currentPos < len(tokens) && tokens[currentPos].ty == labelType; currentPos++ {
-		parameters = append(parameters, tokens[currentPos].value)
+// checkFunctionCorrectness verifies that every variable used by a function is one of its
+// parameters, a parameter of an enclosing lambda, a label bound by an enclosing let or a
+// function, and that every function it calls is one of those, already defined or itself.
+func (i *Interpreter[T]) checkFunctionCorrectness(name string, parameters []string, body Node) error {
+	// convert parameters into look up table
+	bound := make(map[string]bool)
+	for _, p := range parameters {
+		bound[p] = true
 	}
 
-	// consume assignment operator
-	if tokens[currentPos].ty != assignmentOpType {
-		return function{}, currentPos, fmt.Errorf("expected '=' found '%s'", tokens[currentPos].value)
+	return i.checkBound(name, bound, body)
+}
+
+// checkBound checks the labels and calls in body against the labels in bound
+func (i *Interpreter[T]) checkBound(name string, bound map[string]bool, body Node) error {
+	isFunction := func(label string) bool {
+		_, ok := i.funcBindings[label]
+		return ok || label == name
 	}
-	currentPos++
 
-	// the remaining tokens are the function logic
-	funcTokens := tokens[currentPos:]
+	var err error
+	Inspect(body, func(n Node) bool {
+		switch n := n.(type) {
+		case Label:
+			if !bound[n.Name] && !isFunction(n.Name) {
+				err = errorAt(n.Pos(), &UndefinedLabelError{Name: n.Name})
+			}
+		case Call:
+			if !bound[n.Name] && !isFunction(n.Name) {
+				err = errorAt(n.Pos(), &UndefinedFunctionError{Name: n.Name})
+			}
+		case Lambda:
+			// the parameters of a lambda are only bound inside of its body
+			inner := make(map[string]bool, len(bound)+len(n.Params))
+			for p := range bound {
+				inner[p] = true
+			}
+			for _, p := range n.Params {
+				inner[p] = true
+			}
+			err = i.checkBound(name, inner, n.Body)
+			return false
+		case Let:
+			// each binding is visible to the bindings after it and to the body
+			inner := make(map[string]bool, len(bound)+len(n.Bindings))
+			for p := range bound {
+				inner[p] = true
+			}
+			for _, b := range n.Bindings {
+				if err = i.checkBound(name, inner, b.Value); err != nil {
+					return false
+				}
+				inner[b.Label] = true
+			}
+			err = i.checkBound(name, inner, n.Body)
+			return false
+		}
+		return err == nil
+	})
 
-	err = checkFunctionCorrectness(parameters, funcTokens)
+	return err
+}
+
+func (i *Interpreter[T]) assign(a Assignment) (value[T], error) {
+	result, err := i.evalNode(a.Value)
 	if err != nil {
-		return function{}, currentPos, err
+		return result, err
 	}
+	i.labelBindings[a.Label] = result
 
-	return function{
-		name:       funcName,
-		tokens:     funcTokens,
-		parameters: parameters,
-		expOps:     i.expOps,
-		factorOps:  i.factorOps,
-		unaryOps:   i.unaryOps,
-	}, len(tokens), err
+	return result, nil
 }
 
-func checkFunctionCorrectness(parameters []string, tokens []token) error {
-	// convert parameters into look up table
-	paramLookup := make(map[string]bool)
-	for _, p := range parameters {
-		paramLookup[p] = true
+func (i *Interpreter[T]) evalBinaryOp(n BinaryOp) (T, error) {
+	var zero T
+	op, ok := i.binaryOps[n.Op]
+	if !ok {
+		return zero, &UndefinedOperatorError{Op: n.Op}
 	}
 
-	// check that any variable in the function definition has a corresponding parameter
-	for _, tok := range tokens {
-		if tok.ty == labelType {
-			if _, ok := paramLookup[tok.value]; !ok {
-				return fmt.Errorf("undefined variable: %s", tok.value)
-			}
-		}
+	l, err := i.evalScalar(n.Left)
+	if err != nil {
+		return zero, err
+	}
+	r, err := i.evalScalar(n.Right)
+	if err != nil {
+		return zero, err
 	}
 
-	return nil
+	return applyOperator(n.Op, func() (T, error) { return op.apply(l, r) })
 }
 
-func (i *Interpreter) assignment(tokens []token, currentPos int) (result int, pos int) {
-	if tokens[currentPos].ty != labelType {
-		panic("invalid left side in assignment")
+func (i *Interpreter[T]) evalUnaryOp(n UnaryOp) (T, error) {
+	var zero T
+	op, ok := i.unaryOps[n.Op]
+	if !ok {
+		return zero, &UndefinedOperatorError{Op: n.Op}
 	}
 
-	label := tokens[currentPos].value
-	currentPos++
-
-	if tokens[currentPos].ty != assignmentOpType {
-		panic("expecting assignment operator")
+	v, err := i.evalScalar(n.Operand)
+	if err != nil {
+		return zero, err
 	}
-	currentPos++
-	result, pos, _ = i.expression(tokens, currentPos)
-	i.labelBindings[label] = result
 
-	return result, pos
+	return applyOperator(n.Op, func() (T, error) { return op.apply(v) })
 }
 
-func (i *Interpreter) expression(tokens []token, currentPos int) (result int, pos int, err error) {
-	result, pos, err = i.factor(tokens, currentPos)
+// applyOperator runs the implementation of the operator symbol and reports any error
+// or panic it causes as an *OperatorError
+func applyOperator[T any](symbol string, apply func() (T, error)) (v T, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = &OperatorError{Op: symbol, Err: fmt.Errorf("%v", r)}
+		}
+	}()
+
+	v, err = apply()
 	if err != nil {
-		return result, pos, err
+		return v, &OperatorError{Op: symbol, Err: err}
 	}
+	return v, nil
+}
 
-	if pos < len(tokens) && tokens[pos].ty == operatorType {
-		if op, ok := i.expOps[tokens[pos].value]; ok {
-			pos++
-			r, p, err := i.expression(tokens, pos)
-			if err != nil {
-				return r, p, err
-			}
-			result = op(result, r)
-			pos = p
-		}
+// evalConditional only evaluates the branch which is selected by the condition
+func (i *Interpreter[T]) evalConditional(n Conditional) (value[T], error) {
+	c, err := i.evalScalar(n.Cond)
+	if err != nil {
+		return value[T]{}, err
 	}
 
-	if pos < len(tokens) && (tokens[pos].ty != rParen && tokens[pos].ty != commaType) {
-		return result, pos, fmt.Errorf("unexpected token in expression: %s", tokens[pos].value)
+	if i.domain.Truthy(c) {
+		return i.evalNode(n.Then)
 	}
-
-	return result, pos, nil
+	return i.evalNode(n.Else)
 }
 
-func (i *Interpreter) factor(tokens []token, currentPos int) (result int, pos int, err error) {
-	result, currentPos, err = i.term(tokens, currentPos)
-	if err != nil {
-		return result, currentPos, err
-	}
+// evalLet evaluates the bindings of n in a new scope which encloses the body.  Closures
+// created in the bindings capture that scope.
+func (i *Interpreter[T]) evalLet(n Let) (value[T], error) {
+	local := *i
+	local.labelBindings = make(map[string]value[T], len(n.Bindings))
+	local.outer = &scope[T]{vars: i.labelBindings, parent: i.outer}
 
-	if currentPos < len(tokens) {
-		if tokens[currentPos].ty == operatorType {
-			if op, ok := i.factorOps[tokens[currentPos].value]; ok {
-				currentPos++
-				r, p, err := i.factor(tokens, currentPos)
-				if err != nil {
-					return r, p, err
-				}
-				result = op(result, r)
-				currentPos = p
-			}
+	for _, b := range n.Bindings {
+		v, err := local.evalNode(b.Value)
+		if err != nil {
+			return v, err
 		}
+		local.labelBindings[b.Label] = v
 	}
 
-	return result, currentPos, nil
+	return local.evalNode(n.Body)
 }
 
-func (i *Interpreter) term(tokens []token, currentPos int) (result int, pos int, err error) {
-	if currentPos == len(tokens) {
-		return 0, currentPos, fmt.Errorf("expecting term, but none found")
+// evalLogicalOp evaluates `and` and `or`, skipping the right operand when the left
+// operand alone determines the result
+func (i *Interpreter[T]) evalLogicalOp(n LogicalOp) (T, error) {
+	l, err := i.evalScalar(n.Left)
+	if err != nil {
+		return l, err
 	}
-	if tokens[currentPos].ty == lParen {
-		currentPos++
-		result, currentPos, err = i.expression(tokens, currentPos)
-		if err != nil {
-			return result, currentPos, err
-		}
 
-		// consume right paren
-		if currentPos >= len(tokens) || tokens[currentPos].ty != rParen {
-			return 0, currentPos, fmt.Errorf("expected right paren")
+	switch n.Op {
+	case "and":
+		if !i.domain.Truthy(l) {
+			return i.domain.FromBool(false), nil
 		}
-		currentPos++
-	} else if tokens[currentPos].ty == operatorType {
-		// if the operator is not unary then something is wrong
-		if op, ok := i.unaryOps[tokens[currentPos].value]; ok {
-			currentPos++
-			result, currentPos, err = i.term(tokens, currentPos)
-			if err != nil {
-				return 0, currentPos, err
-			}
-			result = op(result)
-		} else {
-			return 0, 0, fmt.Errorf("unexpected token in factor: %s", tokens[currentPos].value)
-		}
-	} else if tokens[currentPos].ty == intType {
-		result, _ = strconv.Atoi(tokens[currentPos].value)
-		currentPos++
-	} else if tokens[currentPos].ty == labelType {
-		// check if this is a function call
-		if len(tokens)-currentPos-1 >= 1 && tokens[currentPos+1].ty == lParen {
-			result, currentPos, err = i.functionCall(tokens, currentPos)
-		} else {
-			result, currentPos, err = i.lookupLabel(tokens, currentPos)
+	case "or":
+		if i.domain.Truthy(l) {
+			return i.domain.FromBool(true), nil
 		}
+	default:
+		var zero T
+		return zero, &UndefinedOperatorError{Op: n.Op}
+	}
+
+	r, err := i.evalScalar(n.Right)
+	if err != nil {
+		return r, err
 	}
+	return i.domain.FromBool(i.domain.Truthy(r)), nil
+}
 
-	return result, currentPos, err
+func (i *Interpreter[T]) evalNot(n Not) (T, error) {
+	v, err := i.evalScalar(n.Operand)
+	if err != nil {
+		return v, err
+	}
+	return i.domain.FromBool(!i.domain.Truthy(v)), nil
 }
 
-func (i *Interpreter) functionCall(tokens []token, currentPos int) (result int, pos int, err error) {
-	funcName := tokens[currentPos].value
-	currentPos++
-	if tokens[currentPos].ty != lParen {
-		return 0, 0, fmt.Errorf("expected lparen")
+// callFunction calls the function named by c.  A label which is bound to a function is
+// called in preference to a function defined with def, so parameters and local bindings
+// shadow global functions.
+func (i *Interpreter[T]) callFunction(c Call) (value[T], error) {
+	var fn callable[T]
+	if v, ok := i.lookupBinding(c.Name); ok && v.fn != nil {
+		fn = v.fn
+	} else if f, ok := i.funcBindings[c.Name]; ok {
+		fn = f
+	} else {
+		return value[T]{}, &UndefinedFunctionError{Name: c.Name}
 	}
-	currentPos++
 
-	// Get function parameters
-	params := make([]int, 0)
-	for currentPos < len(tokens) && tokens[currentPos].ty != rParen {
-		var v int
-		v, currentPos, _ = i.expression(tokens, currentPos)
-		params = append(params, v)
+	args, err := i.evalArgs(c.Args)
+	if err != nil {
+		return value[T]{}, err
+	}
+	return i.call(fn, args, c.Span)
+}
 
-		if tokens[currentPos].ty == commaType {
-			currentPos++
-		}
+// evalApply calls the function which a.Func evaluates to
+func (i *Interpreter[T]) evalApply(a Apply) (value[T], error) {
+	f, err := i.evalNode(a.Func)
+	if err != nil {
+		return f, err
+	}
+	if f.fn == nil {
+		return value[T]{}, errorAt(a.Func.Pos(), &NotAFunctionError{Expr: a.Func.String()})
 	}
 
-	if tokens[currentPos].ty != rParen {
-		return 0, 0, fmt.Errorf("expected rparen")
+	args, err := i.evalArgs(a.Args)
+	if err != nil {
+		return value[T]{}, err
 	}
-	currentPos++
-	if f, ok := i.funcBindings[funcName]; ok {
-		result, err = f.apply(params)
+	return i.call(f.fn, args, a.Span)
+}
+
+func (i *Interpreter[T]) evalArgs(nodes []Node) ([]value[T], error) {
+	args := make([]value[T], 0, len(nodes))
+	for _, arg := range nodes {
+		v, err := i.evalNode(arg)
 		if err != nil {
-			return 0, 0, err
+			return nil, err
 		}
-	} else {
-		return 0, 0, fmt.Errorf("function name not found: " + funcName)
+		args = append(args, v)
 	}
-
-	return result, currentPos, nil
+	return args, nil
 }
 
-func (i *Interpreter) lookupLabel(tokens []token, currentPos int) (result int, pos int, err error) {
-	if tokens[currentPos].ty != labelType {
-		panic("attempting to look up label binding for token that is not a label")
+// lookupLabel finds the value of a label in the current scope and those which enclose
+// it.  The name of a function which is not shadowed by a label evaluates to the function.
+func (i *Interpreter[T]) lookupLabel(label string) (value[T], error) {
+	if v, ok := i.lookupBinding(label); ok {
+		return v, nil
+	}
+	if f, ok := i.funcBindings[label]; ok {
+		return value[T]{fn: f.asValue()}, nil
 	}
 
-	label := tokens[currentPos].value
+	return value[T]{}, &UndefinedLabelError{Name: label}
+}
+
+func (i *Interpreter[T]) lookupBinding(label string) (value[T], bool) {
 	if v, ok := i.labelBindings[label]; ok {
-		return v, currentPos + 1, nil
+		return v, true
 	}
-
-	return 0, currentPos, fmt.Errorf("could not find value for label: " + label)
+	return i.outer.lookup(label)
 }
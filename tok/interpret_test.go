@@ -1,6 +1,8 @@
 package tok
 
 import (
+	"errors"
+	"fmt"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -192,7 +194,7 @@ func Test_CallFunctionWithParameters(t *testing.T) {
 
 	r, err := i.Execute("f(3)")
 	assert.NoError(t, err)
-	assert.Equal(t, 3, r)
+	assert.Equal(t, 6, r)
 }
 
 func Test_CallFunctionMissingParameters_IsError(t *testing.T) {
@@ -204,3 +206,532 @@ func Test_CallFunctionMissingParameters_IsError(t *testing.T) {
 	_, err = i.Execute("f()")
 	assert.Error(t, err)
 }
+
+func Test_BinaryOperatorsAreLeftAssociative(t *testing.T) {
+	i := NewInterpreter()
+	i.AddExpressionOp("+", func(a, b int) int { return a + b })
+	i.AddExpressionOp("-", func(a, b int) int { return a - b })
+	i.AddFactorOp("*", func(a, b int) int { return a * b })
+	i.AddFactorOp("/", func(a, b int) int { return a / b })
+
+	for input, expected := range map[string]int{
+		"8 - 2 - 3":          3,
+		"8 / 4 / 2":          1,
+		"8 - 2 + 3":          9,
+		"8 + 2 - 3":          7,
+		"24 / 4 * 2":         12,
+		"2 * 12 / 4 / 3":     2,
+		"10 - 2 * 3 - 1":     3,
+		"10 - 8 / 4 / 2 - 1": 8,
+		"1 - (2 - 3)":        2,
+	} {
+		v, err := i.Execute(input)
+		assert.NoError(t, err, input)
+		assert.Equal(t, expected, v, input)
+	}
+}
+
+func Test_RightAssociativeOperator(t *testing.T) {
+	pow := func(a, b int) int {
+		r := 1
+		for ; b > 0; b-- {
+			r *= a
+		}
+		return r
+	}
+	i := NewInterpreter()
+	i.AddExpressionOp("-", func(a, b int) int { return a - b })
+	i.AddFactorOp("^", pow, RightAssoc)
+	i.AddFactorOp("*", func(a, b int) int { return a * b })
+
+	for input, expected := range map[string]int{
+		"2 ^ 3 ^ 2":      512,
+		"(2 ^ 3) ^ 2":    64,
+		"2 ^ 3 * 2":      16,
+		"2 * 2 ^ 3":      64,
+		"20 - 2 ^ 2 - 1": 15,
+	} {
+		v, err := i.Execute(input)
+		assert.NoError(t, err, input)
+		assert.Equal(t, expected, v, input)
+	}
+}
+
+func Test_NonAssociativeOperator(t *testing.T) {
+	i := NewInterpreter()
+	i.AddExpressionOp("+", func(a, b int) int { return a + b })
+	i.AddExpressionOp("<", func(a, b int) int {
+		if a < b {
+			return 1
+		}
+		return 0
+	}, NonAssoc)
+
+	v, err := i.Execute("1 < 2")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, v)
+
+	_, err = i.Execute("1 < 2 < 3")
+	assert.Error(t, err)
+
+	_, err = i.Execute("1 < 2 + 3")
+	assert.Error(t, err)
+
+	v, err = i.Execute("(1 < 2) < 3")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, v)
+}
+
+func Test_AddOperatorWithInvalidAssociativity_IsError(t *testing.T) {
+	i := NewInterpreter()
+	err := i.AddExpressionOp("+", func(a, b int) int { return a + b }, Associativity(7))
+	assert.Error(t, err)
+
+	err = i.AddFactorOp("*", func(a, b int) int { return a * b }, LeftAssoc, RightAssoc)
+	assert.Error(t, err)
+}
+
+func Test_BinaryOpPrecedenceLevels(t *testing.T) {
+	pow := func(a, b int) int {
+		r := 1
+		for ; b > 0; b-- {
+			r *= a
+		}
+		return r
+	}
+	less := func(a, b int) int {
+		if a < b {
+			return 1
+		}
+		return 0
+	}
+	i := NewInterpreter()
+	i.AddBinaryOp("|", 1, LeftAssoc, func(a, b int) int { return a | b })
+	i.AddBinaryOp("<", 5, NonAssoc, less)
+	i.AddExpressionOp("+", func(a, b int) int { return a + b })
+	i.AddExpressionOp("-", func(a, b int) int { return a - b })
+	i.AddFactorOp("*", func(a, b int) int { return a * b })
+	i.AddBinaryOp("^", 30, RightAssoc, pow)
+
+	for input, expected := range map[string]int{
+		"2 * 3 ^ 2":         18,
+		"3 ^ 2 * 2":         18,
+		"2 ^ 3 ^ 2":         512,
+		"1 + 2 < 2 * 2":     1,
+		"2 * 2 < 1 + 2":     0,
+		"0 < 1 | 4 < 3":     1,
+		"4 - 1 - 1 < 3 | 0": 1,
+		"(1 | 2) * 3":       9,
+	} {
+		v, err := i.Execute(input)
+		assert.NoError(t, err, input)
+		assert.Equal(t, expected, v, input)
+	}
+}
+
+func Test_AddBinaryOpWithDifferentPrecedence_IsError(t *testing.T) {
+	i := NewInterpreter()
+	assert.NoError(t, i.AddBinaryOp("+", 10, LeftAssoc, func(a, b int) int { return a + b }))
+	assert.Error(t, i.AddBinaryOp("+", 15, LeftAssoc, func(a, b int) int { return a + b }))
+	assert.Error(t, i.AddFactorOp("+", func(a, b int) int { return a + b }))
+	assert.NoError(t, i.AddExpressionOp("+", func(a, b int) int { return a + b }))
+}
+
+func Test_AddBinaryOpWithNegativePrecedence_IsError(t *testing.T) {
+	i := NewInterpreter()
+	err := i.AddBinaryOp("+", -1, LeftAssoc, func(a, b int) int { return a + b })
+	assert.Error(t, err)
+}
+
+func Test_AdjacentBinaryAndUnaryOperators(t *testing.T) {
+	i := NewInterpreter()
+	i.AddExpressionOp("-", func(a, b int) int { return a - b })
+	i.AddFactorOp("*", func(a, b int) int { return a * b })
+	i.AddUnaryOp("-", func(a int) int { return -a })
+	i.AddUnaryOp("--", func(a int) int { return a - 1 })
+
+	for input, expected := range map[string]int{
+		"2*-3":   -6,
+		"2- -3":  5,
+		"-- -3":  -4,
+		"--3*-2": -4,
+	} {
+		v, err := i.Execute(input)
+		assert.NoError(t, err, input)
+		assert.Equal(t, expected, v, input)
+	}
+
+	// the longest operator is always taken so this is 2 followed by the unary operator --
+	_, err := i.Execute("2--3")
+	assert.Error(t, err)
+}
+
+func Test_FunctionCallsAnotherFunction(t *testing.T) {
+	i := NewInterpreter()
+	i.AddExpressionOp("+", func(a, b int) int { return a + b })
+	i.AddFactorOp("*", func(a, b int) int { return a * b })
+	_, err := i.Execute("def g x = x * 2")
+	assert.NoError(t, err)
+	_, err = i.Execute("def h x = g(x) + 1")
+	assert.NoError(t, err)
+	_, err = i.Execute("def k x y = h(g(x)) * y")
+	assert.NoError(t, err)
+
+	r, err := i.Execute("h(3)")
+	assert.NoError(t, err)
+	assert.Equal(t, 7, r)
+
+	r, err = i.Execute("k(3, 2)")
+	assert.NoError(t, err)
+	assert.Equal(t, 26, r)
+}
+
+func Test_FunctionCallsUndefinedFunction_IsError(t *testing.T) {
+	i := NewInterpreter()
+	_, err := i.Execute("def h x = g(x)")
+	assert.Error(t, err)
+}
+
+func Test_FunctionCallsItself(t *testing.T) {
+	i := NewInterpreter()
+	_, err := i.Execute("def f x = f(x)")
+	assert.NoError(t, err)
+
+	// without a way to stop the recursion this never terminates
+	_, err = i.Execute("f(1)")
+	assert.Error(t, err)
+}
+
+func Test_FunctionScopeDoesNotLeakIntoCaller(t *testing.T) {
+	i := NewInterpreter()
+	i.Execute("x = 1")
+	i.Execute("def f x = x")
+
+	r, err := i.Execute("f(5)")
+	assert.NoError(t, err)
+	assert.Equal(t, 5, r)
+
+	r, err = i.Execute("x")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, r)
+}
+
+func Test_Conditional(t *testing.T) {
+	i := NewInterpreter()
+	i.AddExpressionOp("+", func(a, b int) int { return a + b })
+	i.AddExpressionOp("-", func(a, b int) int { return a - b })
+
+	for input, expected := range map[string]int{
+		"if 1 then 2 else 3":                  2,
+		"if 0 then 2 else 3":                  3,
+		"if 1 - 1 then 2 else 3 + 4":          7,
+		"1 + if 0 then 2 else 3":              4,
+		"if if 0 then 1 else 0 then 5 else 6": 6,
+		"if 1 then if 0 then 7 else 8 else 9": 8,
+		"(if 1 then 2 else 3) + 10":           12,
+	} {
+		v, err := i.Execute(input)
+		assert.NoError(t, err, input)
+		assert.Equal(t, expected, v, input)
+	}
+}
+
+func Test_ConditionalOnlyEvaluatesTakenBranch(t *testing.T) {
+	i := NewInterpreter()
+	v, err := i.Execute("if 1 then 2 else undefined")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, v)
+
+	v, err = i.Execute("if 0 then missing() else 3")
+	assert.NoError(t, err)
+	assert.Equal(t, 3, v)
+}
+
+func Test_MalformedConditional_IsError(t *testing.T) {
+	i := NewInterpreter()
+	for _, input := range []string{
+		"if 1 then 2",
+		"if 1 else 2",
+		"if 1 2 else 3",
+		"if then 1 else 2",
+		"if 1 then 2 else",
+		"then",
+		"else = 5",
+		"def if x = x",
+		"def f then = 1",
+	} {
+		_, err := i.Execute(input)
+		assert.Error(t, err, input)
+	}
+}
+
+func Test_RecursiveFunction(t *testing.T) {
+	i := NewInterpreter()
+	i.AddExpressionOp("-", func(a, b int) int { return a - b })
+	i.AddFactorOp("*", func(a, b int) int { return a * b })
+	_, err := i.Execute("def fact n = if n then n * fact(n - 1) else 1")
+	assert.NoError(t, err)
+
+	r, err := i.Execute("fact(5)")
+	assert.NoError(t, err)
+	assert.Equal(t, 120, r)
+}
+
+func Test_SetTruthiness(t *testing.T) {
+	i := NewInterpreter()
+	i.SetTruthiness(func(v int) bool { return v > 0 })
+	i.AddUnaryOp("-", func(a int) int { return -a })
+
+	r, err := i.Execute("if -1 then 1 else 2")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, r)
+
+	i.Execute("def positive x = if x then 1 else 0")
+	r, err = i.Execute("positive(-3)")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, r)
+}
+
+// newTestInterpreter is the interpreter shared by the tests: integer arithmetic,
+// comparisons and a few functions registered from Go
+func newTestInterpreter() IntInterpreter {
+	i := NewInterpreter()
+	i.AddExpressionOp("+", func(a, b int) int { return a + b })
+	i.AddExpressionOp("-", func(a, b int) int { return a - b })
+	i.AddFactorOp("*", func(a, b int) int { return a * b })
+	i.AddFactorOp("/", func(a, b int) int { return a / b })
+	i.AddUnaryOp("-", func(a int) int { return -a })
+	i.AddComparisonOp("==", func(a, b int) bool { return a == b })
+	i.AddComparisonOp("!=", func(a, b int) bool { return a != b })
+	i.AddComparisonOp("<", func(a, b int) bool { return a < b })
+	i.AddComparisonOp("<=", func(a, b int) bool { return a <= b })
+	i.RegisterFunc("max", func(args ...int) (int, error) {
+		if len(args) == 0 {
+			return 0, fmt.Errorf("max of nothing")
+		}
+		m := args[0]
+		for _, a := range args[1:] {
+			if a > m {
+				m = a
+			}
+		}
+		return m, nil
+	})
+	i.RegisterFunc1("abs", func(a int) (int, error) {
+		if a < 0 {
+			return -a, nil
+		}
+		return a, nil
+	})
+	i.RegisterFunc2("sub", func(a, b int) (int, error) { return a - b, nil })
+	i.RegisterFunc0("answer", func() (int, error) { return 42, nil })
+	return i
+}
+
+func Test_ComparisonOperators(t *testing.T) {
+	i := newTestInterpreter()
+	for input, expected := range map[string]int{
+		"1 == 1":                    1,
+		"1 == 2":                    0,
+		"1 != 2":                    1,
+		"1 + 1 == 2":                1,
+		"3 <= 2 + 1":                1,
+		"3 < 2 + 1":                 0,
+		"(1 < 2) == 1":              1,
+		"if 3 == 3 then 10 else 20": 10,
+	} {
+		v, err := i.Execute(input)
+		assert.NoError(t, err, input)
+		assert.Equal(t, expected, v, input)
+	}
+
+	_, err := i.Execute("1 < 2 < 3")
+	assert.Error(t, err)
+
+	v, err := i.Execute("x = 2 == 2")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, v)
+	v, err = i.Execute("x == 1")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, v)
+}
+
+func Test_EqualsCannotBeAnOperator(t *testing.T) {
+	i := NewInterpreter()
+	assert.Error(t, i.AddExpressionOp("=", func(a, b int) int { return a + b }))
+	assert.Error(t, i.AddUnaryOp("=", func(a int) int { return a }))
+	assert.Error(t, i.AddBinaryOp("", 1, LeftAssoc, func(a, b int) int { return a + b }))
+}
+
+func Test_LogicalOperators(t *testing.T) {
+	i := newTestInterpreter()
+	for input, expected := range map[string]int{
+		"1 and 1":                      1,
+		"2 and 3":                      1,
+		"1 and 0":                      0,
+		"0 or 0":                       0,
+		"0 or 5":                       1,
+		"not 0":                        1,
+		"not 7":                        0,
+		"not 1 == 2":                   1,
+		"not 1 and 0":                  0,
+		"not (1 and 0)":                1,
+		"0 and 1 or 1":                 1,
+		"1 or 1 and 0":                 1,
+		"(1 or 1) and 0":               0,
+		"1 < 2 and 2 < 3":              1,
+		"1 + 1 == 2 or 1 == 2 - 5":     1,
+		"if not 0 and 1 then 4 else 5": 4,
+	} {
+		v, err := i.Execute(input)
+		assert.NoError(t, err, input)
+		assert.Equal(t, expected, v, input)
+	}
+}
+
+func Test_LogicalOperatorsShortCircuit(t *testing.T) {
+	i := newTestInterpreter()
+	for input, expected := range map[string]int{
+		"0 and undefined": 0,
+		"1 or missing()":  1,
+	} {
+		v, err := i.Execute(input)
+		assert.NoError(t, err, input)
+		assert.Equal(t, expected, v, input)
+	}
+
+	_, err := i.Execute("1 and undefined")
+	assert.Error(t, err)
+	_, err = i.Execute("0 or missing()")
+	assert.Error(t, err)
+}
+
+func Test_RecursiveFunctionWithComparison(t *testing.T) {
+	i := newTestInterpreter()
+	_, err := i.Execute("def fib n = if n < 2 then n else fib(n - 1) + fib(n - 2)")
+	assert.NoError(t, err)
+
+	r, err := i.Execute("fib(10)")
+	assert.NoError(t, err)
+	assert.Equal(t, 55, r)
+}
+
+func Test_IntInterpreterRejectsFloatLiteral(t *testing.T) {
+	i := NewInterpreter()
+	i.AddFactorOp("*", func(a, b int) int { return a * b })
+	_, err := i.Execute("1.5 * 2")
+	assert.Error(t, err)
+
+	v, err := i.Execute("2 * 3")
+	assert.NoError(t, err)
+	assert.Equal(t, 6, v)
+}
+
+func Test_Introspection(t *testing.T) {
+	i := NewInterpreter()
+	i.AddExpressionOp("+", func(a, b int) int { return a + b })
+	i.AddFactorOp("^", func(a, b int) int { return a }, RightAssoc)
+	i.AddComparisonOp("<", func(a, b int) bool { return a < b })
+	i.AddUnaryOp("-", func(a int) int { return -a })
+
+	assert.Equal(t, []OperatorInfo{
+		{Symbol: "<", Precedence: ComparisonPrecedence, Assoc: NonAssoc},
+		{Symbol: "+", Precedence: ExpressionPrecedence, Assoc: LeftAssoc},
+		{Symbol: "^", Precedence: FactorPrecedence, Assoc: RightAssoc},
+		{Symbol: "-", Unary: true},
+	}, i.Operators())
+
+	_, err := i.ExecuteProgram("x = 1; y = 2; def g a = a + 1; def f a b = g(a) + b")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]int{"x": 1, "y": 2}, i.Variables())
+
+	funcs := i.Functions()
+	assert.Len(t, funcs, 2)
+	assert.Equal(t, "def f a b = (g(a) + b)", funcs[0].String())
+	assert.Equal(t, "def g a = (a + 1)", funcs[1].String())
+
+	i.Reset()
+	assert.Empty(t, i.Variables())
+	assert.Empty(t, i.Functions())
+	assert.Len(t, i.Operators(), 4)
+}
+
+func Test_Let(t *testing.T) {
+	for input, expected := range map[string]int{
+		"let a = 2 in a * 3":                           6,
+		"let a = 2, b = a + 1 in a * b":                6,
+		"(let a = 2 in a) + 1":                         3,
+		"1 + let a = 2 in a + 1":                       4,
+		"let a = 1 in let a = a + 1 in a":              2,
+		"let f = \\x -> x * 2 in f(5)":                 10,
+		"def sq x = let y = x * x in y + y; sq(3)":     18,
+		"def f x = let g = \\y -> x + y in g(1); f(4)": 5,
+		"x = 7; let y = x in y":                        7,
+	} {
+		i := newTestInterpreter()
+		v, err := i.ExecuteProgram(input)
+		assert.NoError(t, err, input)
+		assert.Equal(t, expected, v, input)
+	}
+}
+
+func Test_LetDoesNotBindGlobals(t *testing.T) {
+	i := newTestInterpreter()
+	_, err := i.ExecuteProgram("x = 1; let a = 2, b = 3 in a + b + x")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]int{"x": 1}, i.Variables())
+
+	_, err = i.Execute("a")
+	assert.Error(t, err)
+}
+
+func Test_Let_IsError(t *testing.T) {
+	for input, target := range map[string]interface{}{
+		"let a = 1 in b":                  new(*UndefinedLabelError),
+		"let a = b, b = 1 in a":           new(*UndefinedLabelError),
+		"def f x = let a = x in a + y":    new(*UndefinedLabelError),
+		"def f x = (let a = x in a) + a":  new(*UndefinedLabelError),
+		"def f x = let a = b, b = x in a": new(*UndefinedLabelError),
+		"let a = 1":                       new(*SyntaxError),
+		"let a 1 in a":                    new(*SyntaxError),
+		"let in = 1 in 2":                 new(*SyntaxError),
+		"let a = 1, in a":                 new(*SyntaxError),
+	} {
+		i := newTestInterpreter()
+		_, err := i.ExecuteProgram(input)
+		assert.Error(t, err, input)
+		assert.True(t, errors.As(err, target), "%s: %v", input, err)
+	}
+}
+
+func BenchmarkExecuteShort(b *testing.B) {
+	i := newTestInterpreter()
+	i.AddFactorOp("*", func(a, b int) int { return a * b })
+	i.AddUnaryOp("!", func(a int) int { return -a })
+	i.Execute("x = 4")
+
+	b.ReportAllocs()
+	for n := 0; n < b.N; n++ {
+		for _, input := range []string{"1", "x", "1 + 2", "x * 3 - 1", "!x <= 2"} {
+			if _, err := i.Execute(input); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func Test_TokenizerIsRebuiltWhenOperatorsChange(t *testing.T) {
+	i := NewInterpreter()
+	_, err := i.Execute("1 + 2")
+	assert.Error(t, err)
+
+	i.AddExpressionOp("+", func(a, b int) int { return a + b })
+	v, err := i.Execute("1 + 2")
+	assert.NoError(t, err)
+	assert.Equal(t, 3, v)
+
+	i.AddUnaryOp("~", func(a int) int { return -a })
+	v, err = i.Execute("~1 + 2")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, v)
+}
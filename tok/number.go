@@ -0,0 +1,148 @@
+package tok
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Number is a numeric value which holds either an integer or a floating point
+// number.  When an operator is given a mix of integers and floats the integers
+// are promoted to floats.
+type Number struct {
+	i       int
+	f       float64
+	isFloat bool
+}
+
+// Int returns a Number holding the integer v
+func Int(v int) Number {
+	return Number{i: v}
+}
+
+// Float returns a Number holding the floating point value v
+func Float(v float64) Number {
+	return Number{f: v, isFloat: true}
+}
+
+// IsFloat is true if the Number holds a floating point value
+func (n Number) IsFloat() bool {
+	return n.isFloat
+}
+
+// Int returns the value of the Number as an integer, truncating any fraction
+func (n Number) Int() int {
+	if n.isFloat {
+		return int(n.f)
+	}
+	return n.i
+}
+
+// Float returns the value of the Number as a floating point number
+func (n Number) Float() float64 {
+	if n.isFloat {
+		return n.f
+	}
+	return float64(n.i)
+}
+
+// String formats the Number so that floats can always be told apart from integers
+func (n Number) String() string {
+	if n.isFloat {
+		return formatFloat(n.f)
+	}
+	return strconv.Itoa(n.i)
+}
+
+func formatFloat(f float64) string {
+	s := strconv.FormatFloat(f, 'g', -1, 64)
+	if !strings.ContainsAny(s, ".eIN") {
+		s += ".0"
+	}
+	return s
+}
+
+// NumberInterpreter is an Interpreter which computes with a mix of integers and floats
+type NumberInterpreter = Interpreter[Number]
+
+// NewNumberInterpreter configures a new Interpreter which computes with Numbers.  Its
+// operators should be added with AddNumberOp, AddNumberUnaryOp and AddNumberComparisonOp.
+func NewNumberInterpreter() NumberInterpreter {
+	return New(NumberDomain())
+}
+
+// NumberDomain computes with Numbers.  Literals with a fraction or an exponent are
+// floats and all others are integers.  Non-zero values are true and booleans are the
+// integers 1 and 0.
+func NumberDomain() Domain[Number] {
+	return Domain[Number]{
+		ParseLiteral: func(text string) (Number, error) {
+			if v, err := strconv.Atoi(text); err == nil {
+				return Int(v), nil
+			}
+			v, err := strconv.ParseFloat(text, 64)
+			if err != nil {
+				return Number{}, fmt.Errorf("invalid number: %s", text)
+			}
+			return Float(v), nil
+		},
+		Truthy: func(v Number) bool {
+			if v.isFloat {
+				return v.f != 0
+			}
+			return v.i != 0
+		},
+		FromBool: func(b bool) Number {
+			if b {
+				return Int(1)
+			}
+			return Int(0)
+		},
+	}
+}
+
+// AddNumberOp will add a binary operator with an integer and a float implementation to
+// a NumberInterpreter.  When both operands are integers the integer implementation is
+// used; otherwise the operands are promoted to floats.  Either implementation may be nil:
+// an operator without an integer implementation always promotes its operands and it is
+// an error to give a float to an operator without a float implementation.
+func AddNumberOp(i *NumberInterpreter, symbol string, precedence int, assoc Associativity, ints BinaryOperator[int], floats BinaryOperator[float64]) error {
+	return i.addBinaryOp(symbol, precedence, assoc, func(a, b Number) (Number, error) {
+		if !a.isFloat && !b.isFloat && ints != nil {
+			return Int(ints(a.i, b.i)), nil
+		}
+		if floats == nil {
+			return Number{}, fmt.Errorf("no float implementation")
+		}
+		return Float(floats(a.Float(), b.Float())), nil
+	})
+}
+
+// AddNumberUnaryOp will add a unary operator with an integer and a float implementation to
+// a NumberInterpreter.  Either implementation may be nil as with AddNumberOp.
+func AddNumberUnaryOp(i *NumberInterpreter, symbol string, ints UnaryOperator[int], floats UnaryOperator[float64]) error {
+	return i.addUnaryOp(symbol, func(a Number) (Number, error) {
+		if !a.isFloat && ints != nil {
+			return Int(ints(a.i)), nil
+		}
+		if floats == nil {
+			return Number{}, fmt.Errorf("no float implementation")
+		}
+		return Float(floats(a.Float())), nil
+	})
+}
+
+// AddNumberComparisonOp will add a comparison operator with an integer and a float
+// implementation to a NumberInterpreter.  The result is always the integer 1 or 0.
+// Either implementation may be nil as with AddNumberOp.
+func AddNumberComparisonOp(i *NumberInterpreter, symbol string, ints func(a, b int) bool, floats func(a, b float64) bool) error {
+	return i.addBinaryOp(symbol, ComparisonPrecedence, NonAssoc, func(a, b Number) (Number, error) {
+		if !a.isFloat && !b.isFloat && ints != nil {
+			return i.domain.FromBool(ints(a.i, b.i)), nil
+		}
+		if floats == nil {
+			return Number{}, fmt.Errorf("no float implementation")
+		}
+		return i.domain.FromBool(floats(a.Float(), b.Float())), nil
+	})
+}
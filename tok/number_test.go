@@ -0,0 +1,93 @@
+package tok
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_NumberConversions(t *testing.T) {
+	assert.False(t, Int(3).IsFloat())
+	assert.True(t, Float(3).IsFloat())
+	assert.Equal(t, 3.0, Int(3).Float())
+	assert.Equal(t, 2, Float(2.9).Int())
+}
+
+func Test_NumberString(t *testing.T) {
+	assert.Equal(t, "3", Int(3).String())
+	assert.Equal(t, "3.0", Float(3).String())
+	assert.Equal(t, "0.001", Float(1e-3).String())
+	assert.Equal(t, "1e+21", Float(1e21).String())
+}
+
+func newNumberInterpreter() NumberInterpreter {
+	i := NewNumberInterpreter()
+	AddNumberOp(&i, "+", ExpressionPrecedence, LeftAssoc,
+		func(a, b int) int { return a + b },
+		func(a, b float64) float64 { return a + b })
+	AddNumberOp(&i, "-", ExpressionPrecedence, LeftAssoc,
+		func(a, b int) int { return a - b },
+		func(a, b float64) float64 { return a - b })
+	AddNumberOp(&i, "*", FactorPrecedence, LeftAssoc,
+		func(a, b int) int { return a * b },
+		func(a, b float64) float64 { return a * b })
+	AddNumberOp(&i, "/", FactorPrecedence, LeftAssoc, nil, func(a, b float64) float64 { return a / b })
+	AddNumberOp(&i, "%", FactorPrecedence, LeftAssoc, func(a, b int) int { return a % b }, nil)
+	AddNumberUnaryOp(&i, "-", func(a int) int { return -a }, func(a float64) float64 { return -a })
+	AddNumberComparisonOp(&i, "<",
+		func(a, b int) bool { return a < b },
+		func(a, b float64) bool { return a < b })
+	return i
+}
+
+func Test_FloatArithmetic(t *testing.T) {
+	i := newNumberInterpreter()
+	for input, expected := range map[string]Number{
+		"1.5 * 2":              Float(3),
+		"1.5 + 1.25":           Float(2.75),
+		"2 * 3":                Int(6),
+		"-2.5":                 Float(-2.5),
+		"1e-3 * 1000":          Float(1),
+		"7 / 2":                Float(3.5),
+		"7 % 2":                Int(1),
+		"1 < 1.5":              Int(1),
+		"2.5 < 1":              Int(0),
+		"if 0.5 then 1 else 2": Int(1),
+		"if 0.0 then 1 else 2": Int(2),
+	} {
+		v, err := i.Execute(input)
+		assert.NoError(t, err, input)
+		assert.Equal(t, expected, v, input)
+	}
+}
+
+func Test_FloatVariablesAndFunctions(t *testing.T) {
+	i := newNumberInterpreter()
+	_, err := i.Execute("rate = 0.25")
+	assert.NoError(t, err)
+	_, err = i.Execute("def tax x = x * rate")
+	assert.Error(t, err)
+	_, err = i.Execute("def tax x r = x * r")
+	assert.NoError(t, err)
+
+	v, err := i.Execute("tax(200, rate)")
+	assert.NoError(t, err)
+	assert.Equal(t, Float(50), v)
+}
+
+func Test_OperatorWithoutFloatImplementation_IsError(t *testing.T) {
+	i := newNumberInterpreter()
+	_, err := i.Execute("7.5 % 2")
+	assert.Error(t, err)
+
+	i = NewNumberInterpreter()
+	AddNumberUnaryOp(&i, "-", func(a int) int { return -a }, nil)
+	_, err = i.Execute("-1.5")
+	assert.Error(t, err)
+}
+
+func Test_AddNumberOpWithDifferentPrecedence_IsError(t *testing.T) {
+	i := newNumberInterpreter()
+	err := AddNumberOp(&i, "+", FactorPrecedence, LeftAssoc, nil, func(a, b float64) float64 { return a + b })
+	assert.Error(t, err)
+}
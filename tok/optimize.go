@@ -0,0 +1,406 @@
+package tok
+
+import (
+	"fmt"
+)
+
+// maxInlineSize is the largest number of nodes in the body of a function which will
+// be inlined, and maxInlineDepth how deeply inlined functions may themselves be inlined
+const (
+	maxInlineSize  = 24
+	maxInlineDepth = 4
+)
+
+// constant is a value which the optimizer computed in place of the node at Span.  It
+// keeps the text of that node so that an error which names it reads as it was written.
+type constant[T any] struct {
+	v    T
+	text string
+	Span
+}
+
+func (constant[T]) node() {}
+
+func (n constant[T]) String() string {
+	return n.text
+}
+
+// SetOptimize turns the optimizer on or off; it is on by default.  The optimizer folds
+// the operators marked with MarkPure whose operands are constant, skips the branches of
+// conditions which are constant and inlines small functions which are not recursive.
+// Results and errors are the same either way, but turning it off leaves evaluation
+// exactly as it is written, which helps when debugging an operator.
+func (i *Interpreter[T]) SetOptimize(enabled bool) {
+	i.optimize = enabled
+	i.refresh()
+}
+
+// MarkPure marks operators whose results only depend on their operands, so that the
+// optimizer may compute them before evaluation when their operands are constant.  A
+// symbol which is both a binary and a unary operator marks both.  Replacing an operator
+// clears its mark.
+func (i *Interpreter[T]) MarkPure(symbols ...string) error {
+	for _, symbol := range symbols {
+		binary, isBinary := i.binaryOps[symbol]
+		unary, isUnary := i.unaryOps[symbol]
+		if !isBinary && !isUnary {
+			return &UndefinedOperatorError{Op: symbol}
+		}
+		if isBinary {
+			binary.pure = true
+			i.binaryOps[symbol] = binary
+		}
+		if isUnary {
+			unary.pure = true
+			i.unaryOps[symbol] = unary
+		}
+	}
+	i.refresh()
+	return nil
+}
+
+// refresh compiles every function again after a change which may affect how it is
+// optimized, such as a new definition or operator.  A function is never changed once it
+// is bound, because values and copies of the Interpreter made by Compile may share it,
+// so each is replaced by a recompiled copy.
+func (i *Interpreter[T]) refresh() {
+	i.generation++
+	for name, f := range i.funcBindings {
+		if f.host == nil {
+			i.funcBindings[name] = i.recompile(f)
+		}
+	}
+}
+
+// recompile returns a copy of f compiled for the operators and functions as they are
+// now.  If other functions are inlined into its code then it is also compiled without
+// them for when it is taken as a value.
+func (i *Interpreter[T]) recompile(f *function[T]) *function[T] {
+	recompiled := *f
+	body, inlined := i.optimizeFunction(f.name, f.parameters, f.body, true)
+	recompiled.code = i.compileFunction(f.parameters, body)
+	recompiled.value = nil
+	if inlined {
+		v := recompiled
+		body, _ = i.optimizeFunction(f.name, f.parameters, f.body, false)
+		v.code = i.compileFunction(f.parameters, body)
+		recompiled.value = &v
+	}
+	return &recompiled
+}
+
+// optimizer rewrites a tree into one which computes the same result more quickly
+type optimizer[T any] struct {
+	interpreter *Interpreter[T]
+
+	// bound are the labels bound by the parameters, lets and lambdas around the node
+	bound map[string]bool
+
+	// function is the name of the function being optimized
+	function string
+
+	// inline is true where calls may be inlined, which is only in the body of a function
+	// called by name: that body is compiled again whenever a function it calls changes.
+	// A statement may bind its variables to functions later, and a closure or a function
+	// taken as a value may be called after the functions it calls have been redefined.
+	inline bool
+	depth  int
+
+	// renamed counts the parameters renamed by inlining so that each gets a new name,
+	// and inlined counts the calls which were inlined
+	renamed *int
+	inlined *int
+}
+
+func (i *Interpreter[T]) newOptimizer(function string, params []string, inline bool) *optimizer[T] {
+	o := &optimizer[T]{interpreter: i, bound: make(map[string]bool, len(params)), function: function, inline: inline, renamed: new(int), inlined: new(int)}
+	for _, p := range params {
+		o.bound[p] = true
+	}
+	return o
+}
+
+// optimizeStatement optimizes a statement of a Program.  Its calls are not inlined.
+func (i *Interpreter[T]) optimizeStatement(node Node) Node {
+	if !i.optimize {
+		return node
+	}
+	return i.newOptimizer("", nil, false).node(node)
+}
+
+// optimizeFunction optimizes the body of a function, inlining its calls if inline is set,
+// and reports whether any call was inlined
+func (i *Interpreter[T]) optimizeFunction(name string, params []string, body Node, inline bool) (Node, bool) {
+	if !i.optimize {
+		return body, false
+	}
+	o := i.newOptimizer(name, params, inline)
+	return o.node(body), *o.inlined > 0
+}
+
+// with returns a copy of the optimizer in which labels are also bound
+func (o *optimizer[T]) with(labels ...string) *optimizer[T] {
+	inner := *o
+	inner.bound = make(map[string]bool, len(o.bound)+len(labels))
+	for label := range o.bound {
+		inner.bound[label] = true
+	}
+	for _, label := range labels {
+		inner.bound[label] = true
+	}
+	return &inner
+}
+
+// constant is the value of node if it is known before evaluation
+func (o *optimizer[T]) constant(node Node) (T, bool) {
+	switch n := node.(type) {
+	case constant[T]:
+		return n.v, true
+	case Literal:
+		v, err := o.interpreter.domain.ParseLiteral(n.Text)
+		return v, err == nil
+	}
+	var zero T
+	return zero, false
+}
+
+// fold computes an operator when it can; an operator which fails is left to fail
+// when it is evaluated so that the error is reported as usual
+func (o *optimizer[T]) fold(node Node, symbol string, apply func() (T, error)) Node {
+	v, err := applyOperator(symbol, apply)
+	if err != nil {
+		return node
+	}
+	return constant[T]{v: v, text: node.String(), Span: node.Pos()}
+}
+
+// bool is the constant FromBool(b) in place of node
+func (o *optimizer[T]) bool(b bool, node Node) Node {
+	return constant[T]{v: o.interpreter.domain.FromBool(b), text: node.String(), Span: node.Pos()}
+}
+
+func (o *optimizer[T]) node(node Node) Node {
+	domain := o.interpreter.domain
+	switch n := node.(type) {
+	case UnaryOp:
+		n.Operand = o.node(n.Operand)
+		op, ok := o.interpreter.unaryOps[n.Op]
+		if a, isConst := o.constant(n.Operand); ok && op.pure && isConst {
+			return o.fold(n, n.Op, func() (T, error) { return op.apply(a) })
+		}
+		return n
+	case BinaryOp:
+		n.Left = o.node(n.Left)
+		n.Right = o.node(n.Right)
+		op, ok := o.interpreter.binaryOps[n.Op]
+		a, leftConst := o.constant(n.Left)
+		b, rightConst := o.constant(n.Right)
+		if ok && op.pure && leftConst && rightConst {
+			return o.fold(n, n.Op, func() (T, error) { return op.apply(a, b) })
+		}
+		return n
+	case Not:
+		n.Operand = o.node(n.Operand)
+		if v, ok := o.constant(n.Operand); ok {
+			return o.bool(!domain.Truthy(v), n)
+		}
+		return n
+	case LogicalOp:
+		n.Left = o.node(n.Left)
+		n.Right = o.node(n.Right)
+		l, ok := o.constant(n.Left)
+		if !ok || (n.Op != "and" && n.Op != "or") {
+			return n
+		}
+		// the left operand decides the result, or the result is the right operand
+		if decided := domain.Truthy(l) == (n.Op == "or"); decided {
+			return o.bool(domain.Truthy(l), n)
+		}
+		if r, ok := o.constant(n.Right); ok {
+			return o.bool(domain.Truthy(r), n)
+		}
+		return n
+	case Conditional:
+		// both branches are kept so that the conditional reads as written in an error;
+		// the compiler skips the branch which is not taken
+		n.Cond = o.node(n.Cond)
+		if c, ok := o.constant(n.Cond); ok {
+			n.Cond = constant[T]{v: c, text: n.Cond.String(), Span: n.Cond.Pos()}
+		}
+		n.Then = o.node(n.Then)
+		n.Else = o.node(n.Else)
+		return n
+	case Call:
+		n.Args = o.nodes(n.Args)
+		if inlined, ok := o.inlineCall(n); ok {
+			return inlined
+		}
+		return n
+	case Apply:
+		// a call whose result is called is kept so that an error names it as written
+		if call, ok := n.Func.(Call); ok {
+			call.Args = o.nodes(call.Args)
+			n.Func = call
+		} else {
+			n.Func = o.node(n.Func)
+		}
+		n.Args = o.nodes(n.Args)
+		return n
+	case Lambda:
+		// like a function taken as a value, a closure may be called after the functions
+		// it calls have been redefined
+		inner := o.with(n.Params...)
+		inner.inline = false
+		n.Body = inner.node(n.Body)
+		return n
+	case Let:
+		inner := o
+		bindings := make([]Assignment, len(n.Bindings))
+		for b, binding := range n.Bindings {
+			binding.Value = inner.node(binding.Value)
+			bindings[b] = binding
+			inner = inner.with(binding.Label)
+		}
+		n.Bindings = bindings
+		n.Body = inner.node(n.Body)
+		return n
+	case Assignment:
+		n.Value = o.node(n.Value)
+		return n
+	default:
+		return node
+	}
+}
+
+func (o *optimizer[T]) nodes(nodes []Node) []Node {
+	optimized := make([]Node, len(nodes))
+	for n, node := range nodes {
+		optimized[n] = o.node(node)
+	}
+	return optimized
+}
+
+// inlineCall replaces a call of a small function with its body.  The arguments are
+// bound by a let to new names for the parameters so that each is evaluated once, in
+// order, as for a call.  The nodes of the body take the position of the call so that
+// errors are reported at the call as they would be for a call.
+func (o *optimizer[T]) inlineCall(c Call) (Node, bool) {
+	if !o.inline || o.depth >= maxInlineDepth || c.Name == o.function || o.bound[c.Name] {
+		return nil, false
+	}
+	f, ok := o.interpreter.funcBindings[c.Name]
+	if !ok || f.host != nil || len(f.parameters) != len(c.Args) || !o.inlinable(f) {
+		return nil, false
+	}
+
+	renames := make(map[string]string, len(f.parameters))
+	bindings := make([]Assignment, len(f.parameters))
+	for p, param := range f.parameters {
+		*o.renamed++
+		renames[param] = fmt.Sprintf("%s#%d", param, *o.renamed)
+		bindings[p] = Assignment{Label: renames[param], Value: c.Args[p], Span: c.Args[p].Pos()}
+	}
+
+	inner := o.with()
+	inner.depth++
+	for _, name := range renames {
+		inner.bound[name] = true
+	}
+	body := inner.node(rewrite(f.body, renames, c.Span))
+	*o.inlined++
+	if len(bindings) == 0 {
+		return body, true
+	}
+	return Let{Bindings: bindings, Body: body, Span: c.Span}, true
+}
+
+// inlinable is true if the body of f is small, does not call f or its parameters and
+// only uses the parameters of f and functions which are not shadowed where it would be
+// inlined.  A call of a parameter is not inlined because an error from it would name
+// the renamed parameter.
+func (o *optimizer[T]) inlinable(f *function[T]) bool {
+	params := make(map[string]bool, len(f.parameters))
+	for _, p := range f.parameters {
+		params[p] = true
+	}
+
+	size := 0
+	ok := true
+	Inspect(f.body, func(n Node) bool {
+		size++
+		switch n := n.(type) {
+		case Label:
+			ok = ok && (params[n.Name] || !o.bound[n.Name])
+		case Call:
+			ok = ok && n.Name != f.name && !params[n.Name] && !o.bound[n.Name]
+		case Lambda, Let, Assignment, FuncDef:
+			ok = false
+		}
+		return ok && size <= maxInlineSize
+	})
+	return ok && size <= maxInlineSize
+}
+
+// rewrite copies the body of a function to be inlined, renaming its parameters and
+// moving every node to span
+func rewrite(node Node, renames map[string]string, span Span) Node {
+	name := func(name string) string {
+		if renamed, ok := renames[name]; ok {
+			return renamed
+		}
+		return name
+	}
+	nodes := func(nodes []Node) []Node {
+		rewritten := make([]Node, len(nodes))
+		for i, n := range nodes {
+			rewritten[i] = rewrite(n, renames, span)
+		}
+		return rewritten
+	}
+
+	switch n := node.(type) {
+	case Literal:
+		n.Span = span
+		return n
+	case Label:
+		n.Name = name(n.Name)
+		n.Span = span
+		return n
+	case UnaryOp:
+		n.Operand = rewrite(n.Operand, renames, span)
+		n.Span = span
+		return n
+	case BinaryOp:
+		n.Left = rewrite(n.Left, renames, span)
+		n.Right = rewrite(n.Right, renames, span)
+		n.Span = span
+		return n
+	case LogicalOp:
+		n.Left = rewrite(n.Left, renames, span)
+		n.Right = rewrite(n.Right, renames, span)
+		n.Span = span
+		return n
+	case Not:
+		n.Operand = rewrite(n.Operand, renames, span)
+		n.Span = span
+		return n
+	case Conditional:
+		n.Cond = rewrite(n.Cond, renames, span)
+		n.Then = rewrite(n.Then, renames, span)
+		n.Else = rewrite(n.Else, renames, span)
+		n.Span = span
+		return n
+	case Call:
+		n.Name = name(n.Name)
+		n.Args = nodes(n.Args)
+		n.Span = span
+		return n
+	case Apply:
+		n.Func = rewrite(n.Func, renames, span)
+		n.Args = nodes(n.Args)
+		n.Span = span
+		return n
+	default:
+		return node
+	}
+}
@@ -0,0 +1,224 @@
+package tok
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// folded renders node with the values which the optimizer computed in place of the
+// text they replaced
+func folded(node Node) string {
+	s := node.String()
+	Inspect(node, func(n Node) bool {
+		if c, ok := n.(constant[int]); ok {
+			s = strings.Replace(s, c.text, fmt.Sprint(c.v), 1)
+		}
+		return true
+	})
+	return s
+}
+
+func Test_ConstantFolding(t *testing.T) {
+	count := 0
+	i := newTestInterpreter()
+	i.AddFactorOp("*", func(a, b int) int {
+		count++
+		return a * b
+	})
+	assert.NoError(t, i.MarkPure("*", "-"))
+
+	p, err := i.Compile("x * (60 * 60 * 24)")
+	assert.NoError(t, err)
+	for _, x := range []int{1, 2, 3} {
+		v, err := p.Eval(map[string]int{"x": x})
+		assert.NoError(t, err)
+		assert.Equal(t, x*86400, v)
+	}
+	// the constant is computed once and each evaluation only multiplies by x
+	assert.Equal(t, 2+3, count)
+}
+
+func Test_ConstantFoldingOnlyFoldsPureOperators(t *testing.T) {
+	count := 0
+	i := newTestInterpreter()
+	i.AddFactorOp("*", func(a, b int) int {
+		count++
+		return a * b
+	})
+	p, err := i.Compile("2 * 3")
+	assert.NoError(t, err)
+	p.Eval(nil)
+	p.Eval(nil)
+	assert.Equal(t, 2, count)
+
+	// replacing an operator clears its mark
+	i.MarkPure("*")
+	i.AddFactorOp("*", func(a, b int) int { return a * b * 10 })
+	v, err := p.Eval(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 60, v)
+	assert.False(t, i.Operators()[len(i.Operators())-2].Pure)
+}
+
+func Test_SetOptimize(t *testing.T) {
+	count := 0
+	i := newTestInterpreter()
+	i.AddFactorOp("*", func(a, b int) int {
+		count++
+		return a * b
+	})
+	i.MarkPure("*")
+	i.SetOptimize(false)
+
+	p, err := i.Compile("2 * 3")
+	assert.NoError(t, err)
+	p.Eval(nil)
+	p.Eval(nil)
+	assert.Equal(t, 2, count)
+
+	i.SetOptimize(true)
+	count = 0
+	p.Eval(nil)
+	p.Eval(nil)
+	assert.Equal(t, 1, count)
+}
+
+func Test_OptimizeStatement(t *testing.T) {
+	i := newTestInterpreter()
+	i.MarkPure("+", "*", "-", "<")
+	_, err := i.ExecuteProgram("def sq x = x * x")
+	assert.NoError(t, err)
+
+	for input, expected := range map[string]string{
+		"1 + 2 * 3":              "7",
+		"-(2 + 3)":               "-5",
+		"if 1 < 2 then x else y": "(if 1 then x else y)",
+		"not 0 and x":            "(1 and x)",
+		"0 and x":                "0",
+		"1 or x":                 "1",
+		"1 / 0":                  "(1 / 0)",
+		"sq(3)":                  "sq(3)",
+	} {
+		node, err := i.Parse(input)
+		assert.NoError(t, err, input)
+		optimized := i.optimizeStatement(node)
+		assert.Equal(t, expected, folded(optimized), input)
+		assert.Equal(t, node.String(), optimized.String(), input)
+	}
+}
+
+func Test_OptimizeFunction(t *testing.T) {
+	i := newTestInterpreter()
+	i.MarkPure("+", "*", "-", "<")
+	_, err := i.ExecuteProgram("def sq x = x * x; def inc x = x + 1; def f n = if n < 2 then n else f(n - 1)")
+	assert.NoError(t, err)
+
+	for input, expected := range map[string]string{
+		"sq(3)":             "(let x#1 = 3 in (x#1 * x#1))",
+		"sq(inc(y))":        "(let x#2 = (let x#1 = y in (x#1 + 1)) in (x#2 * x#2))",
+		"f(3)":              "f(3)",
+		"g(3)":              "g(3)",
+		"(\\x -> sq(x))(y)": "(\\ x -> sq(x))(y)",
+	} {
+		node, err := i.Parse(input)
+		assert.NoError(t, err, input)
+		optimized, _ := i.optimizeFunction("t", []string{"y", "g"}, node, true)
+		assert.Equal(t, expected, folded(optimized), input)
+	}
+}
+
+func Test_InliningIsNotShadowed(t *testing.T) {
+	i := newTestInterpreter()
+	v, err := i.ExecuteProgram(`
+def g x = x + 1
+def h x = g(x) * 2
+h(1)`)
+	assert.NoError(t, err)
+	assert.Equal(t, 4, v)
+
+	// redefining g changes h although g was inlined into it
+	v, err = i.ExecuteProgram("def g x = x + 10; h(1)")
+	assert.NoError(t, err)
+	assert.Equal(t, 22, v)
+
+	// a parameter or variable bound to a function is called instead of g
+	v, err = i.ExecuteProgram("def k g x = g(x); k(\\x -> x * 3, 2)")
+	assert.NoError(t, err)
+	assert.Equal(t, 6, v)
+	v, err = i.ExecuteProgram("add = \\x -> g(x); def g x = x - 1; add(5)")
+	assert.NoError(t, err)
+	assert.Equal(t, 4, v)
+	v, err = i.ExecuteProgram("g = \\x -> 0; g(5)")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, v)
+}
+
+func Test_InliningIsUndoneByHostFunctions(t *testing.T) {
+	i := newTestInterpreter()
+	_, err := i.ExecuteProgram("def g x = x + 1; def f x = g(x)")
+	assert.NoError(t, err)
+
+	// replacing g with a function from Go changes f although g was inlined into it
+	i.RegisterFunc1("g", func(a int) (int, error) { return a * 100, nil })
+	v, err := i.Execute("f(1)")
+	assert.NoError(t, err)
+	assert.Equal(t, 100, v)
+
+	assert.NoError(t, i.Bind("g", func(a int) int { return a * 1000 }))
+	v, err = i.Execute("f(1)")
+	assert.NoError(t, err)
+	assert.Equal(t, 1000, v)
+
+	p, err := i.Compile("f(1)")
+	assert.NoError(t, err)
+	i.Reset()
+	_, err = p.Eval(nil)
+	var undefined *UndefinedFunctionError
+	assert.True(t, errors.As(err, &undefined))
+}
+
+func Test_InlinedErrorsAreReportedAtTheCall(t *testing.T) {
+	i := newTestInterpreter()
+	_, err := i.ExecuteProgram("def f x = 1 / x\ndef g x = 3 + f(x)\ny = g(0)")
+	var pe *PositionError
+	assert.True(t, errors.As(err, &pe))
+	assert.Equal(t, "line 3, column 5: operator /: runtime error: integer divide by zero", err.Error())
+}
+
+func Test_MarkPure_IsError(t *testing.T) {
+	i := newTestInterpreter()
+	var undefined *UndefinedOperatorError
+	assert.True(t, errors.As(i.MarkPure("+", "%"), &undefined))
+	assert.Equal(t, "%", undefined.Op)
+}
+
+func Test_OptimizerAgreesWithUnoptimized(t *testing.T) {
+	for _, program := range vmPrograms {
+		optimized := newTestInterpreter()
+		optimized.MarkPure("+", "-", "*", "/", "==", "!=", "<", "<=")
+		plain := newTestInterpreter()
+		plain.SetOptimize(false)
+
+		expected, expectedErr := evalCompiled(&plain, program)
+		v, err := evalCompiled(&optimized, program)
+		assert.Equal(t, expected, v, program)
+		if expectedErr == nil {
+			assert.NoError(t, err, program)
+		} else {
+			assert.EqualError(t, err, expectedErr.Error(), program)
+		}
+
+		expected, expectedErr = plain.ExecuteProgram(program)
+		v, err = optimized.ExecuteProgram(program)
+		assert.Equal(t, expected, v, program)
+		if expectedErr == nil {
+			assert.NoError(t, err, program)
+		} else {
+			assert.EqualError(t, err, expectedErr.Error(), program)
+		}
+	}
+}
@@ -0,0 +1,589 @@
+package tok
+
+// Parse converts a single statement into an abstract syntax tree using the
+// operators that have been defined on the Interpreter.  The result can be
+// evaluated with Eval.
+func (i *Interpreter[T]) Parse(text string) (node Node, err error) {
+	defer recoverError(&err)
+	tokens, err := i.tokenizer.tokenize(text)
+	if err != nil {
+		return nil, err
+	}
+
+	// the nesting is counted in a copy so that parsing does not change the Interpreter
+	parser := *i
+	parser.nesting = 0
+	return parser.parseTokens(tokens)
+}
+
+// keywords are labels which have a fixed meaning in the grammar and cannot be
+// used as the names of variables or functions
+var keywords = map[string]used{
+	"def":  {},
+	"if":   {},
+	"then": {},
+	"else": {},
+	"and":  {},
+	"or":   {},
+	"not":  {},
+	"fun":  {},
+	"let":  {},
+	"in":   {},
+}
+
+// logicalOps are the keywords which act as short circuiting binary operators
+var logicalOps = map[string]opInfo{
+	"or":  {precedence: OrPrecedence, assoc: LeftAssoc},
+	"and": {precedence: AndPrecedence, assoc: LeftAssoc},
+}
+
+func isKeyword(tok token, keyword string) bool {
+	return tok.ty == labelType && tok.value == keyword
+}
+
+func checkNotKeyword(tok token) error {
+	if _, ok := keywords[tok.value]; ok && tok.ty == labelType {
+		return syntaxErrorf("unexpected keyword: %s", tok.value)
+	}
+	return nil
+}
+
+func (i *Interpreter[T]) parseTokens(tokens []token) (Node, error) {
+	if len(tokens) == 0 {
+		return nil, errorAt(tokenSpan(tokens, 0), syntaxErrorf("expecting statement, but none found"))
+	}
+
+	var node Node
+	var pos int
+	var err error
+	if len(tokens) >= 3 && tokens[0].ty == labelType && tokens[1].ty == assignmentOpType {
+		node, pos, err = i.assignment(tokens, 0)
+	} else if isKeyword(tokens[0], "def") {
+		node, pos, err = i.functionDef(tokens, 0)
+	} else {
+		node, pos, err = i.expression(tokens, 0)
+	}
+	if err == nil && pos != len(tokens) {
+		err = syntaxErrorf("unexpected token in expression: %s", tokens[pos].value)
+	}
+	if err != nil {
+		return nil, errorAt(tokenSpan(tokens, pos), err)
+	}
+	return node, nil
+}
+
+// tokenSpan is the span of the token at pos or, if pos is past the last token, the
+// empty span at the end of the input
+func tokenSpan(tokens []token, pos int) Span {
+	if pos < len(tokens) {
+		return tokens[pos].span
+	}
+	if len(tokens) == 0 {
+		start := Position{Line: 1, Column: 1}
+		return Span{Start: start, End: start}
+	}
+	end := tokens[len(tokens)-1].span.End
+	return Span{Start: end, End: end}
+}
+
+func (i *Interpreter[T]) functionDef(tokens []token, currentPos int) (node Node, pos int, err error) {
+	if !isKeyword(tokens[currentPos], "def") {
+		return nil, currentPos, syntaxErrorf("expected 'def' found '%s'", tokens[currentPos].value)
+	}
+	start := tokens[currentPos].span
+	currentPos++
+
+	if currentPos >= len(tokens) || tokens[currentPos].ty != labelType {
+		return nil, currentPos, syntaxErrorf("expected function name after 'def'")
+	}
+	if err := checkNotKeyword(tokens[currentPos]); err != nil {
+		return nil, currentPos, err
+	}
+	funcName := tokens[currentPos].value
+	currentPos++
+
+	// each label from now until an assignment operator is encountered is a function parameter
+	parameters := make([]string, 0)
+	for ; currentPos < len(tokens) && tokens[currentPos].ty == labelType; currentPos++ {
+		if err := checkNotKeyword(tokens[currentPos]); err != nil {
+			return nil, currentPos, err
+		}
+		parameters = append(parameters, tokens[currentPos].value)
+	}
+
+	// consume assignment operator
+	if currentPos >= len(tokens) {
+		return nil, currentPos, syntaxErrorf("expected '=' but none found")
+	}
+	if tokens[currentPos].ty != assignmentOpType {
+		return nil, currentPos, syntaxErrorf("expected '=' found '%s'", tokens[currentPos].value)
+	}
+	currentPos++
+
+	// the remaining tokens are the function logic
+	body, pos, err := i.expression(tokens, currentPos)
+	if err != nil {
+		return nil, pos, err
+	}
+
+	return FuncDef{
+		Name:   funcName,
+		Params: parameters,
+		Body:   body,
+		Span:   join(start, body.Pos()),
+	}, pos, nil
+}
+
+func (i *Interpreter[T]) assignment(tokens []token, currentPos int) (node Node, pos int, err error) {
+	if tokens[currentPos].ty != labelType {
+		return nil, currentPos, syntaxErrorf("invalid left side in assignment: %s", tokens[currentPos].value)
+	}
+	if err := checkNotKeyword(tokens[currentPos]); err != nil {
+		return nil, currentPos, err
+	}
+
+	label := tokens[currentPos].value
+	start := tokens[currentPos].span
+	currentPos++
+
+	if tokens[currentPos].ty != assignmentOpType {
+		return nil, currentPos, syntaxErrorf("expecting assignment operator found '%s'", tokens[currentPos].value)
+	}
+	currentPos++
+
+	value, pos, err := i.expression(tokens, currentPos)
+	if err != nil {
+		return nil, pos, err
+	}
+
+	return Assignment{Label: label, Value: value, Span: join(start, value.Pos())}, pos, nil
+}
+
+// nest enters a level of nesting, failing if the input is nested too deeply.  leave
+// returns to the level before.
+func (i *Interpreter[T]) nest() error {
+	if i.nesting >= maxNesting {
+		return syntaxErrorf("expression is nested too deeply")
+	}
+	i.nesting++
+	return nil
+}
+
+func (i *Interpreter[T]) leave() {
+	i.nesting--
+}
+
+func (i *Interpreter[T]) expression(tokens []token, currentPos int) (node Node, pos int, err error) {
+	return i.binaryExpression(tokens, currentPos, 0)
+}
+
+// binaryExpression parses a sequence of terms separated by binary operators using
+// precedence climbing.  Only operators which bind at least as tightly as minPower
+// are consumed; the remainder are left for the caller.
+func (i *Interpreter[T]) binaryExpression(tokens []token, currentPos int, minPower int) (node Node, pos int, err error) {
+	if err := i.nest(); err != nil {
+		return nil, currentPos, err
+	}
+	defer i.leave()
+
+	if currentPos < len(tokens) && isKeyword(tokens[currentPos], "not") {
+		node, pos, err = i.not(tokens, currentPos)
+	} else {
+		node, pos, err = i.term(tokens, currentPos)
+	}
+	if err != nil {
+		return node, pos, err
+	}
+
+	// each operator applied here puts the node one level deeper in the tree, which
+	// counts as nesting although the parser does not recurse for it
+	folds := 0
+	defer func() { i.nesting -= folds }()
+
+	for pos < len(tokens) {
+		symbol := tokens[pos].value
+		op, ok := i.infixOperator(tokens[pos])
+		if !ok || op.leftPower() < minPower {
+			break
+		}
+		if err := i.nest(); err != nil {
+			return nil, pos, err
+		}
+		folds++
+		pos++
+
+		var r Node
+		r, pos, err = i.binaryExpression(tokens, pos, op.rightPower())
+		if err != nil {
+			return r, pos, err
+		}
+		if _, ok := logicalOps[symbol]; ok {
+			node = LogicalOp{Op: symbol, Left: node, Right: r, Span: join(node.Pos(), r.Pos())}
+		} else {
+			node = BinaryOp{Op: symbol, Left: node, Right: r, Span: join(node.Pos(), r.Pos())}
+		}
+
+		if op.assoc == NonAssoc && pos < len(tokens) {
+			if next, ok := i.infixOperator(tokens[pos]); ok && next.precedence == op.precedence {
+				return nil, pos, syntaxErrorf("non-associative operator %s cannot be chained with %s", symbol, tokens[pos].value)
+			}
+		}
+	}
+
+	return node, pos, nil
+}
+
+// infixOperator finds the binary operator, if any, which tok refers to
+func (i *Interpreter[T]) infixOperator(tok token) (opInfo, bool) {
+	switch tok.ty {
+	case operatorType:
+		op, ok := i.binaryOps[tok.value]
+		return op.opInfo, ok
+	case labelType:
+		op, ok := logicalOps[tok.value]
+		return op, ok
+	}
+	return opInfo{}, false
+}
+
+// not parses the `not` keyword whose operand extends over any operator that binds more
+// tightly than `and`
+func (i *Interpreter[T]) not(tokens []token, currentPos int) (node Node, pos int, err error) {
+	if !isKeyword(tokens[currentPos], "not") {
+		return nil, currentPos, syntaxErrorf("expected 'not' found '%s'", tokens[currentPos].value)
+	}
+	start := tokens[currentPos].span
+	currentPos++
+
+	operand, pos, err := i.binaryExpression(tokens, currentPos, 2*NotPrecedence)
+	if err != nil {
+		return nil, pos, err
+	}
+
+	return Not{Operand: operand, Span: join(start, operand.Pos())}, pos, nil
+}
+
+// leftPower is how tightly the operator binds to the operand on its left.  Right associative
+// operators bind slightly more tightly than the other operators of the same precedence so that
+// a chain of them groups to the right while a mixed chain is still read from left to right.
+func (op opInfo) leftPower() int {
+	if op.assoc == RightAssoc {
+		return 2*op.precedence + 1
+	}
+	return 2 * op.precedence
+}
+
+// rightPower is the minimum binding power an operator must have to be part of the right operand
+func (op opInfo) rightPower() int {
+	if op.assoc == RightAssoc {
+		return 2*op.precedence + 1
+	}
+	return 2*op.precedence + 2
+}
+
+func (i *Interpreter[T]) term(tokens []token, currentPos int) (node Node, pos int, err error) {
+	if currentPos == len(tokens) {
+		return nil, currentPos, syntaxErrorf("expecting term, but none found")
+	}
+	if err := i.nest(); err != nil {
+		return nil, currentPos, err
+	}
+	defer i.leave()
+
+	start := tokens[currentPos].span
+	switch tokens[currentPos].ty {
+	case lambdaType:
+		return i.lambda(tokens, currentPos)
+	case lParen:
+		currentPos++
+		node, currentPos, err = i.expression(tokens, currentPos)
+		if err == nil && (currentPos >= len(tokens) || tokens[currentPos].ty != rParen) {
+			err = syntaxErrorf("expected right paren")
+		}
+		if err != nil {
+			var recovered bool
+			if currentPos, recovered = i.recoverFrom(tokens, currentPos, err, false); !recovered {
+				return nil, currentPos, err
+			}
+			node, err = invalid{Span: tokenSpan(tokens, currentPos)}, nil
+		}
+
+		// consume right paren
+		currentPos++
+		return i.applications(tokens, currentPos, start, node)
+	case operatorType:
+		// if the operator is not unary then something is wrong
+		if _, ok := i.unaryOps[tokens[currentPos].value]; ok {
+			op := tokens[currentPos].value
+			start := tokens[currentPos].span
+			currentPos++
+			node, currentPos, err = i.term(tokens, currentPos)
+			if err != nil {
+				return nil, currentPos, err
+			}
+			node = UnaryOp{Op: op, Operand: node, Span: join(start, node.Pos())}
+		} else {
+			return nil, currentPos, syntaxErrorf("unexpected token in factor: %s", tokens[currentPos].value)
+		}
+	case intType, floatType:
+		// make sure the literal is valid in this interpreter's domain
+		if _, err := i.domain.ParseLiteral(tokens[currentPos].value); err != nil {
+			return nil, currentPos, &SyntaxError{Msg: err.Error()}
+		}
+		node = Literal{Text: tokens[currentPos].value, Span: tokens[currentPos].span}
+		currentPos++
+	case labelType:
+		if isKeyword(tokens[currentPos], "if") {
+			return i.conditional(tokens, currentPos)
+		}
+		if isKeyword(tokens[currentPos], "fun") {
+			return i.lambda(tokens, currentPos)
+		}
+		if isKeyword(tokens[currentPos], "let") {
+			return i.let(tokens, currentPos)
+		}
+		if err := checkNotKeyword(tokens[currentPos]); err != nil {
+			return nil, currentPos, err
+		}
+
+		// check if this is a function call
+		if len(tokens)-currentPos-1 >= 1 && tokens[currentPos+1].ty == lParen {
+			node, currentPos, err = i.functionCall(tokens, currentPos)
+			if err != nil {
+				return nil, currentPos, err
+			}
+			return i.applications(tokens, currentPos, start, node)
+		} else {
+			node = Label{Name: tokens[currentPos].value, Span: tokens[currentPos].span}
+			currentPos++
+		}
+	default:
+		return nil, currentPos, syntaxErrorf("unexpected token in term: %s", tokens[currentPos].value)
+	}
+
+	return node, currentPos, err
+}
+
+func (i *Interpreter[T]) functionCall(tokens []token, currentPos int) (node Node, pos int, err error) {
+	funcName := tokens[currentPos].value
+	start := tokens[currentPos].span
+	currentPos++
+
+	args, end, currentPos, err := i.arguments(tokens, currentPos)
+	if err != nil {
+		return nil, currentPos, err
+	}
+
+	return Call{Name: funcName, Args: args, Span: join(start, end)}, currentPos, nil
+}
+
+// applications parses any argument lists which follow the function expression fn, such
+// as the second call in f(1)(2), each of which calls the result of what comes before it
+func (i *Interpreter[T]) applications(tokens []token, currentPos int, start Span, fn Node) (node Node, pos int, err error) {
+	for currentPos < len(tokens) && tokens[currentPos].ty == lParen {
+		var args []Node
+		var end Span
+		args, end, currentPos, err = i.arguments(tokens, currentPos)
+		if err != nil {
+			return nil, currentPos, err
+		}
+		fn = Apply{Func: fn, Args: args, Span: join(start, end)}
+	}
+	return fn, currentPos, nil
+}
+
+// arguments parses a parenthesized list of expressions separated by commas and returns
+// them along with the span of the closing paren
+func (i *Interpreter[T]) arguments(tokens []token, currentPos int) (args []Node, end Span, pos int, err error) {
+	if currentPos >= len(tokens) || tokens[currentPos].ty != lParen {
+		return nil, end, currentPos, syntaxErrorf("expected lparen")
+	}
+	currentPos++
+
+	// Get function parameters
+	args = make([]Node, 0)
+	for currentPos < len(tokens) && tokens[currentPos].ty != rParen {
+		var arg Node
+		arg, currentPos, err = i.expression(tokens, currentPos)
+		if err == nil && currentPos < len(tokens) && tokens[currentPos].ty != commaType && tokens[currentPos].ty != rParen {
+			err = syntaxErrorf("unexpected token in arguments: %s", tokens[currentPos].value)
+		}
+		if err != nil {
+			var recovered bool
+			if currentPos, recovered = i.recoverFrom(tokens, currentPos, err, true); !recovered {
+				return nil, end, currentPos, err
+			}
+			arg = invalid{Span: tokenSpan(tokens, currentPos)}
+		}
+		args = append(args, arg)
+
+		if currentPos < len(tokens) && tokens[currentPos].ty == commaType {
+			currentPos++
+		}
+	}
+
+	if currentPos >= len(tokens) || tokens[currentPos].ty != rParen {
+		return nil, end, currentPos, syntaxErrorf("expected rparen")
+	}
+	end = tokens[currentPos].span
+	currentPos++
+
+	return args, end, currentPos, nil
+}
+
+// lambda parses `\ Label* -> Expression` or `fun Label* -> Expression`.  Like a
+// conditional its body extends as far to the right as possible.
+func (i *Interpreter[T]) lambda(tokens []token, currentPos int) (node Node, pos int, err error) {
+	if tokens[currentPos].ty != lambdaType && !isKeyword(tokens[currentPos], "fun") {
+		return nil, currentPos, syntaxErrorf("expected '\\' or 'fun' found '%s'", tokens[currentPos].value)
+	}
+	start := tokens[currentPos].span
+	currentPos++
+
+	params := make([]string, 0)
+	for ; currentPos < len(tokens) && tokens[currentPos].ty == labelType; currentPos++ {
+		if err := checkNotKeyword(tokens[currentPos]); err != nil {
+			return nil, currentPos, err
+		}
+		params = append(params, tokens[currentPos].value)
+	}
+
+	if currentPos >= len(tokens) || tokens[currentPos].ty != arrowType {
+		return nil, currentPos, syntaxErrorf("expected '->' after parameters")
+	}
+	currentPos++
+
+	body, currentPos, err := i.expression(tokens, currentPos)
+	if err != nil {
+		return nil, currentPos, err
+	}
+
+	return Lambda{Params: params, Body: body, Span: join(start, body.Pos())}, currentPos, nil
+}
+
+// conditional parses `if Expression then Expression else Expression`
+func (i *Interpreter[T]) conditional(tokens []token, currentPos int) (node Node, pos int, err error) {
+	if !isKeyword(tokens[currentPos], "if") {
+		return nil, currentPos, syntaxErrorf("expected 'if' found '%s'", tokens[currentPos].value)
+	}
+	start := tokens[currentPos].span
+	currentPos++
+
+	cond, currentPos, err := i.expression(tokens, currentPos)
+	if err != nil {
+		return nil, currentPos, err
+	}
+	if currentPos >= len(tokens) || !isKeyword(tokens[currentPos], "then") {
+		return nil, currentPos, syntaxErrorf("expected 'then' after condition")
+	}
+	currentPos++
+
+	then, currentPos, err := i.expression(tokens, currentPos)
+	if err != nil {
+		return nil, currentPos, err
+	}
+	if currentPos >= len(tokens) || !isKeyword(tokens[currentPos], "else") {
+		return nil, currentPos, syntaxErrorf("expected 'else' after 'then' branch")
+	}
+	currentPos++
+
+	els, currentPos, err := i.expression(tokens, currentPos)
+	if err != nil {
+		return nil, currentPos, err
+	}
+
+	return Conditional{Cond: cond, Then: then, Else: els, Span: join(start, els.Pos())}, currentPos, nil
+}
+
+// let parses `let Label = Expression [, Label = Expression]* in Expression`.  Like a
+// conditional its body extends as far to the right as possible.
+func (i *Interpreter[T]) let(tokens []token, currentPos int) (node Node, pos int, err error) {
+	if !isKeyword(tokens[currentPos], "let") {
+		return nil, currentPos, syntaxErrorf("expected 'let' found '%s'", tokens[currentPos].value)
+	}
+	start := tokens[currentPos].span
+	currentPos++
+
+	bindings := make([]Assignment, 0)
+	for {
+		if currentPos >= len(tokens) || tokens[currentPos].ty != labelType {
+			return nil, currentPos, syntaxErrorf("expected label in 'let'")
+		}
+		if err := checkNotKeyword(tokens[currentPos]); err != nil {
+			return nil, currentPos, err
+		}
+		label := tokens[currentPos]
+		currentPos++
+
+		if currentPos >= len(tokens) || tokens[currentPos].ty != assignmentOpType {
+			return nil, currentPos, syntaxErrorf("expected '=' after '%s'", label.value)
+		}
+		currentPos++
+
+		var value Node
+		value, currentPos, err = i.expression(tokens, currentPos)
+		if err != nil {
+			return nil, currentPos, err
+		}
+		bindings = append(bindings, Assignment{Label: label.value, Value: value, Span: join(label.span, value.Pos())})
+
+		if currentPos >= len(tokens) || tokens[currentPos].ty != commaType {
+			break
+		}
+		currentPos++
+	}
+
+	if currentPos >= len(tokens) || !isKeyword(tokens[currentPos], "in") {
+		return nil, currentPos, syntaxErrorf("expected 'in' after bindings")
+	}
+	currentPos++
+
+	body, currentPos, err := i.expression(tokens, currentPos)
+	if err != nil {
+		return nil, currentPos, err
+	}
+
+	return Let{Bindings: bindings, Body: body, Span: join(start, body.Pos())}, currentPos, nil
+}
+
+// invalid stands in for a part of a statement which could not be parsed so that the
+// parser can carry on after recovering from an error
+type invalid struct {
+	Span
+}
+
+func (invalid) node() {}
+
+func (invalid) String() string {
+	return "<invalid>"
+}
+
+// recoverFrom records err, which was found at currentPos, and skips to the next ')' or, if
+// atComma is set, the next ',' which is not nested in parentheses.  It returns the position
+// of that token and whether parsing can carry on from there.  The parser only recovers when
+// collecting diagnostics and when there is such a token to synchronize on.
+func (i *Interpreter[T]) recoverFrom(tokens []token, currentPos int, err error, atComma bool) (int, bool) {
+	if i.diagnostics == nil {
+		return currentPos, false
+	}
+
+	depth := 0
+	pos := currentPos
+	for ; pos < len(tokens); pos++ {
+		ty := tokens[pos].ty
+		if depth == 0 && (ty == rParen || (atComma && ty == commaType)) {
+			break
+		}
+		if ty == lParen {
+			depth++
+		} else if ty == rParen {
+			depth--
+		}
+	}
+	if pos >= len(tokens) {
+		return currentPos, false
+	}
+
+	*i.diagnostics = append(*i.diagnostics, positionOf(tokenSpan(tokens, currentPos), err))
+	return pos, true
+}
@@ -0,0 +1,107 @@
+package tok
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ParseExpression(t *testing.T) {
+	i := NewInterpreter()
+	i.AddExpressionOp("+", func(a, b int) int { return a + b })
+	i.AddFactorOp("*", func(a, b int) int { return a * b })
+	i.AddUnaryOp("-", func(a int) int { return -a })
+
+	node, err := i.Parse("-2 * x + f(1, y)")
+	assert.NoError(t, err)
+	assert.Equal(t, "((-2 * x) + f(1, y))", node.String())
+
+	sum := node.(BinaryOp)
+	assert.Equal(t, "*", sum.Left.(BinaryOp).Op)
+	assert.Equal(t, Call{
+		Name: "f",
+		Args: []Node{
+			Literal{Text: "1", Span: Span{Start: Position{Line: 1, Column: 12, Offset: 11}, End: Position{Line: 1, Column: 13, Offset: 12}}},
+			Label{Name: "y", Span: Span{Start: Position{Line: 1, Column: 15, Offset: 14}, End: Position{Line: 1, Column: 16, Offset: 15}}},
+		},
+		Span: Span{Start: Position{Line: 1, Column: 10, Offset: 9}, End: Position{Line: 1, Column: 17, Offset: 16}},
+	}, sum.Right)
+	assert.Equal(t, Span{Start: Position{Line: 1, Column: 1, Offset: 0}, End: Position{Line: 1, Column: 17, Offset: 16}}, node.Pos())
+}
+
+func Test_ParseAssignment(t *testing.T) {
+	i := NewInterpreter()
+	node, err := i.Parse("x = 5")
+	assert.NoError(t, err)
+	assert.Equal(t, "x = 5", node.String())
+	assert.Equal(t, "5", node.(Assignment).Value.(Literal).Text)
+}
+
+func Test_ParseFunctionDef(t *testing.T) {
+	i := NewInterpreter()
+	i.AddFactorOp("*", func(a, b int) int { return a * b })
+	node, err := i.Parse("def f x y = y * x")
+	assert.NoError(t, err)
+	assert.Equal(t, "def f x y = (y * x)", node.String())
+}
+
+func Test_ParseDoesNotEvaluate(t *testing.T) {
+	i := NewInterpreter()
+	_, err := i.Parse("x = y")
+	assert.NoError(t, err)
+
+	_, err = i.Execute("x")
+	assert.Error(t, err)
+}
+
+func Test_ParseIncompleteFunctionDef_IsError(t *testing.T) {
+	i := NewInterpreter()
+	for _, input := range []string{
+		"def",
+		"def f",
+		"def f x",
+		"def f x =",
+	} {
+		_, err := i.Parse(input)
+		assert.Error(t, err, input)
+	}
+}
+
+func Test_EvalParsedNode(t *testing.T) {
+	i := NewInterpreter()
+	i.AddExpressionOp("+", func(a, b int) int { return a + b })
+	node, err := i.Parse("2 + 3")
+	assert.NoError(t, err)
+
+	for n := 0; n < 2; n++ {
+		v, err := i.Eval(node)
+		assert.NoError(t, err)
+		assert.Equal(t, 5, v)
+	}
+}
+
+func Test_InspectVisitsEveryNode(t *testing.T) {
+	i := NewInterpreter()
+	i.AddExpressionOp("+", func(a, b int) int { return a + b })
+	node, err := i.Parse("f(x, 2) + y")
+	assert.NoError(t, err)
+
+	labels := make([]string, 0)
+	Inspect(node, func(n Node) bool {
+		if l, ok := n.(Label); ok {
+			labels = append(labels, l.Name)
+		}
+		return true
+	})
+	assert.Equal(t, []string{"x", "y"}, labels)
+}
+
+func Test_ParseLet(t *testing.T) {
+	i := NewInterpreter()
+	i.AddExpressionOp("+", func(a, b int) int { return a + b })
+
+	node, err := i.Parse("let a = 1, b = a + 2 in b + 1")
+	assert.NoError(t, err)
+	assert.Equal(t, "(let a = 1, b = (a + 2) in (b + 1))", node.String())
+	assert.Equal(t, Span{Start: Position{Line: 1, Column: 1, Offset: 0}, End: Position{Line: 1, Column: 30, Offset: 29}}, node.Pos())
+}
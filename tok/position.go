@@ -0,0 +1,123 @@
+package tok
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Position is a location in source text.  Lines and columns count from 1 and
+// columns count runes; Offset is the number of bytes from the start of the text.
+type Position struct {
+	Line   int
+	Column int
+	Offset int
+}
+
+// Span is the range of source text from Start up to, but not including, End
+type Span struct {
+	Start Position
+	End   Position
+}
+
+// Pos returns the span of source text that a Node was parsed from
+func (s Span) Pos() Span {
+	return s
+}
+
+func (p Position) String() string {
+	return fmt.Sprintf("line %d, column %d", p.Line, p.Column)
+}
+
+// join returns the span which starts at the start of a and ends at the end of b
+func join(a, b Span) Span {
+	return Span{Start: a.Start, End: b.End}
+}
+
+// PositionError is an error which was caused by the source text in Span
+type PositionError struct {
+	Span Span
+	Err  error
+}
+
+func (e *PositionError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Span.Start, e.Err)
+}
+
+func (e *PositionError) Unwrap() error {
+	return e.Err
+}
+
+// errorAt attaches span to err unless err already has a position
+func errorAt(span Span, err error) error {
+	var pe *PositionError
+	if err == nil || errors.As(err, &pe) {
+		return err
+	}
+	return &PositionError{Span: span, Err: err}
+}
+
+// positionOf returns the *PositionError in err or, if it has no position, places err at span
+func positionOf(span Span, err error) *PositionError {
+	var pe *PositionError
+	if errors.As(err, &pe) {
+		return pe
+	}
+	return &PositionError{Span: span, Err: err}
+}
+
+// RenderSpan returns the line of source on which span starts with a caret underneath
+// each character of the span.  A span which covers no characters, such as the end of
+// the input, is marked with a single caret.
+func RenderSpan(source string, span Span) string {
+	lines := strings.Split(source, "\n")
+	if span.Start.Line < 1 || span.Start.Line > len(lines) {
+		return ""
+	}
+	line := []rune(strings.TrimRight(lines[span.Start.Line-1], "\r"))
+
+	// keep tabs so that the caret lines up with the source however tabs are displayed
+	var marker strings.Builder
+	for c := 0; c < span.Start.Column-1 && c < len(line); c++ {
+		if line[c] == '\t' {
+			marker.WriteRune('\t')
+		} else {
+			marker.WriteRune(' ')
+		}
+	}
+
+	width := 1
+	if span.End.Line == span.Start.Line && span.End.Column > span.Start.Column {
+		width = span.End.Column - span.Start.Column
+	} else if span.End.Line > span.Start.Line && len(line) >= span.Start.Column {
+		width = len(line) - span.Start.Column + 1
+	}
+	marker.WriteString(strings.Repeat("^", width))
+
+	return string(line) + "\n" + marker.String()
+}
+
+// FormatError describes err and, if it has a position, renders the part of source
+// which caused it with RenderSpan
+func FormatError(source string, err error) string {
+	var pe *PositionError
+	if !errors.As(err, &pe) {
+		return err.Error()
+	}
+	rendered := RenderSpan(source, pe.Span)
+	if rendered == "" {
+		return err.Error()
+	}
+	return err.Error() + "\n" + rendered
+}
+
+// shift moves a position found in a statement to where it is in the whole program
+// given that the statement starts at base
+func (p Position) shift(base Position) Position {
+	if p.Line == 1 {
+		p.Column += base.Column - 1
+	}
+	p.Line += base.Line - 1
+	p.Offset += base.Offset
+	return p
+}
@@ -0,0 +1,76 @@
+package tok
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func errorSpan(t *testing.T, err error) Span {
+	var pe *PositionError
+	if !errors.As(err, &pe) {
+		t.Fatalf("expected an error with a position, got: %v", err)
+	}
+	return pe.Span
+}
+
+func Test_ErrorsHavePositions(t *testing.T) {
+	for input, expected := range map[string]Span{
+		"":            {Start: Position{Line: 1, Column: 1}, End: Position{Line: 1, Column: 1}},
+		"1 + $":       {Start: Position{Line: 1, Column: 5, Offset: 4}, End: Position{Line: 1, Column: 6, Offset: 5}},
+		"1 + 2 3":     {Start: Position{Line: 1, Column: 7, Offset: 6}, End: Position{Line: 1, Column: 8, Offset: 7}},
+		"(1 + 2":      {Start: Position{Line: 1, Column: 7, Offset: 6}, End: Position{Line: 1, Column: 7, Offset: 6}},
+		"1 + abc * 2": {Start: Position{Line: 1, Column: 5, Offset: 4}, End: Position{Line: 1, Column: 8, Offset: 7}},
+		"2 * f(1)":    {Start: Position{Line: 1, Column: 5, Offset: 4}, End: Position{Line: 1, Column: 9, Offset: 8}},
+		"def f x = y": {Start: Position{Line: 1, Column: 11, Offset: 10}, End: Position{Line: 1, Column: 12, Offset: 11}},
+	} {
+		i := newTestInterpreter()
+		_, err := i.Execute(input)
+		assert.Error(t, err, input)
+		assert.Equal(t, expected, errorSpan(t, err), input)
+	}
+}
+
+func Test_FunctionBodyErrorIsReportedAtCall(t *testing.T) {
+	i := newTestInterpreter()
+	_, err := i.Execute("def f x = f(x) + 1")
+	assert.NoError(t, err)
+
+	_, err = i.Execute("1 + f(1)")
+	assert.Error(t, err)
+	assert.Equal(t, Span{Start: Position{Line: 1, Column: 5, Offset: 4}, End: Position{Line: 1, Column: 9, Offset: 8}}, errorSpan(t, err))
+}
+
+func Test_ProgramErrorPositionsAreInTheProgram(t *testing.T) {
+	i := newTestInterpreter()
+	_, err := i.ExecuteProgram("x = 1\n  y = 2; x + z")
+	assert.Error(t, err)
+	assert.Equal(t, Span{Start: Position{Line: 2, Column: 14, Offset: 19}, End: Position{Line: 2, Column: 15, Offset: 20}}, errorSpan(t, err))
+	assert.EqualError(t, err, "line 2, column 14: could not find value for label: z")
+}
+
+func Test_RenderSpan(t *testing.T) {
+	source := "x = 1\n\tfoo + bar\n"
+	assert.Equal(t, "\tfoo + bar\n\t      ^^^", RenderSpan(source, Span{
+		Start: Position{Line: 2, Column: 8},
+		End:   Position{Line: 2, Column: 11},
+	}))
+
+	// an empty span at the end of a line is marked with one caret
+	assert.Equal(t, "x = 1\n     ^", RenderSpan(source, Span{
+		Start: Position{Line: 1, Column: 6},
+		End:   Position{Line: 1, Column: 6},
+	}))
+
+	assert.Equal(t, "", RenderSpan(source, Span{Start: Position{Line: 9, Column: 1}}))
+}
+
+func Test_FormatError(t *testing.T) {
+	i := newTestInterpreter()
+	source := "1 + 2 * y"
+	_, err := i.Execute(source)
+	assert.Equal(t, "line 1, column 9: could not find value for label: y\n1 + 2 * y\n        ^", FormatError(source, err))
+
+	assert.Equal(t, "plain", FormatError(source, errors.New("plain")))
+}
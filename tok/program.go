@@ -0,0 +1,272 @@
+package tok
+
+import (
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// Result is the value computed by a single statement of a program
+type Result[T any] struct {
+	// Line is the line of the program on which the statement begins, counting from 1
+	Line  int
+	Text  string
+	Node  Node
+	Value T
+}
+
+// statement is the source text of one statement of a program
+type statement struct {
+	start Position
+	text  string
+}
+
+// ExecuteProgram runs each statement of a program in order and returns the value of
+// the last one.  Statements are separated by newlines or ';'.  A newline inside of
+// parentheses does not end a statement, so long expressions may be split across lines.
+// If a statement fails then the error reports the line on which it begins.
+func (i *Interpreter[T]) ExecuteProgram(text string) (T, error) {
+	var zero T
+	results, err := i.ExecuteProgramResults(text)
+	if err != nil {
+		return zero, err
+	}
+	if len(results) == 0 {
+		return zero, syntaxErrorf("expecting statement, but none found")
+	}
+
+	return results[len(results)-1].Value, nil
+}
+
+// ExecuteReader reads a program from r and runs it with ExecuteProgram
+func (i *Interpreter[T]) ExecuteReader(r io.Reader) (T, error) {
+	text, err := io.ReadAll(r)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+
+	return i.ExecuteProgram(string(text))
+}
+
+// ExecuteProgramResults runs each statement of a program in order as with ExecuteProgram
+// and returns the result of every statement.  If a statement fails then the results of
+// the statements which ran before it are returned along with the error.
+func (i *Interpreter[T]) ExecuteProgramResults(text string) ([]Result[T], error) {
+	results := make([]Result[T], 0)
+	for _, s := range splitStatements(text) {
+		node, err := i.Parse(s.text)
+		if err != nil {
+			return results, s.locate(err)
+		}
+		v, err := i.Eval(node)
+		if err != nil {
+			return results, s.locate(err)
+		}
+		results = append(results, Result[T]{Line: s.start.Line, Text: s.text, Node: node, Value: v})
+	}
+
+	return results, nil
+}
+
+// ParseProgram parses every statement of a program without evaluating it and reports
+// all of the syntax errors that it finds rather than only the first.  After an error
+// the parser skips ahead to the next ',' or ')' of the enclosing call or parentheses, or
+// to the next statement, and carries on.  The nodes of the statements which parsed
+// without error are returned in order along with the errors, which are sorted by position.
+func (i *Interpreter[T]) ParseProgram(text string) ([]Node, []*PositionError) {
+	nodes := make([]Node, 0)
+	diagnostics := make([]*PositionError, 0)
+	for _, s := range splitStatements(text) {
+		found := make([]*PositionError, 0)
+		parser := *i
+		parser.diagnostics = &found
+
+		node, err := parser.Parse(s.text)
+		if err != nil {
+			found = append(found, positionOf(Span{Start: Position{Line: 1, Column: 1}, End: Position{Line: 1, Column: 1}}, err))
+		}
+		if len(found) == 0 {
+			nodes = append(nodes, node)
+		}
+
+		sort.SliceStable(found, func(a, b int) bool {
+			return found[a].Span.Start.Offset < found[b].Span.Start.Offset
+		})
+		for _, d := range found {
+			diagnostics = append(diagnostics, s.locate(d))
+		}
+	}
+
+	return nodes, diagnostics
+}
+
+// Program is a parsed program which can be evaluated many times with different values
+// for its variables without being tokenized or parsed again
+type Program[T any] struct {
+	interpreter *Interpreter[T]
+	statements  []statement
+	nodes       []Node
+
+	// code holds the optimized and compiled form of each node, or nil for a node which
+	// is evaluated by walking the tree.  functions holds the functions each node sees,
+	// which include those the program defined before it, and defined holds the error of
+	// a definition which failed.  They are made again when generation is behind that of
+	// the Interpreter.
+	mu         sync.Mutex
+	optimized  []Node
+	code       []*chunk[T]
+	functions  []map[string]*function[T]
+	defined    []error
+	generation int
+}
+
+// Compile parses a program, as run by ExecuteProgram, so that it can be evaluated
+// later with Program.Eval.  The first syntax error is returned.  The Program uses the
+// operators, functions and variables of the Interpreter as they are when it is evaluated.
+func (i *Interpreter[T]) Compile(text string) (*Program[T], error) {
+	p := &Program[T]{interpreter: i, statements: splitStatements(text)}
+	if len(p.statements) == 0 {
+		return nil, syntaxErrorf("expecting statement, but none found")
+	}
+
+	for _, s := range p.statements {
+		node, err := i.Parse(s.text)
+		if err != nil {
+			return nil, s.locate(err)
+		}
+		p.nodes = append(p.nodes, node)
+	}
+
+	return p, nil
+}
+
+// Eval runs the statements of the program with vars bound as variables and returns
+// the value of the last one.  vars shadow the variables of the Interpreter.  Variables
+// assigned and functions defined by the program only last for this evaluation, so the
+// Interpreter is not changed and Eval may be called from several goroutines at once as
+// long as nothing else changes the Interpreter.
+func (p *Program[T]) Eval(vars map[string]T) (v T, err error) {
+	defer recoverError(&err)
+
+	i := *p.interpreter
+	i.labelBindings = make(map[string]value[T], len(vars))
+	i.outer = &scope[T]{vars: p.interpreter.labelBindings, parent: p.interpreter.outer}
+	for label, v := range vars {
+		i.labelBindings[label] = value[T]{v: v}
+	}
+
+	var result value[T]
+	optimized, code, functions, defined := p.compiled()
+	for n, node := range optimized {
+		i.funcBindings = functions[n]
+		if _, ok := node.(FuncDef); ok {
+			// the functions of the program were defined when it was compiled
+			result, err = value[T]{}, defined[n]
+		} else if code[n] != nil {
+			result, err = i.execute(code[n])
+		} else {
+			result, err = i.evalNode(node)
+		}
+		if err != nil {
+			return v, p.statements[n].locate(err)
+		}
+	}
+	if result.fn != nil {
+		return v, nil
+	}
+	return result.v, nil
+}
+
+// compiled returns the optimized nodes of the program, their compiled form, the
+// functions each of them sees and the errors of its definitions.  They are made again if
+// the operators or functions of the Interpreter have changed.  The functions the program
+// defines are compiled here, once, in a copy of the Interpreter.
+func (p *Program[T]) compiled() ([]Node, []*chunk[T], []map[string]*function[T], []error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.code == nil || p.generation != p.interpreter.generation {
+		i := *p.interpreter
+		p.optimized = make([]Node, len(p.nodes))
+		p.code = make([]*chunk[T], len(p.nodes))
+		p.functions = make([]map[string]*function[T], len(p.nodes))
+		p.defined = make([]error, len(p.nodes))
+		for n, node := range p.nodes {
+			if def, ok := node.(FuncDef); ok {
+				functions := make(map[string]*function[T], len(i.funcBindings)+1)
+				for name, f := range i.funcBindings {
+					functions[name] = f
+				}
+				i.funcBindings = functions
+				p.defined[n] = errorAt(def.Pos(), i.defineFunction(def))
+				p.optimized[n] = node
+			} else {
+				p.optimized[n] = i.optimizeStatement(node)
+				p.code[n] = i.compile(p.optimized[n])
+			}
+			p.functions[n] = i.funcBindings
+		}
+		p.generation = p.interpreter.generation
+	}
+	return p.optimized, p.code, p.functions, p.defined
+}
+
+// locate moves the position of an error in the statement to where it is in the program
+func (s statement) locate(err error) *PositionError {
+	pe := positionOf(Span{Start: Position{Line: 1, Column: 1}, End: Position{Line: 1, Column: 1}}, err)
+	return &PositionError{
+		Span: Span{Start: pe.Span.Start.shift(s.start), End: pe.Span.End.shift(s.start)},
+		Err:  pe.Err,
+	}
+}
+
+// splitStatements breaks a program into statements at each ';' and at each newline
+// which is not inside of parentheses.  Blank statements are dropped.
+func splitStatements(text string) []statement {
+	statements := make([]statement, 0)
+	pos := Position{Line: 1, Column: 1}
+	var start Position
+	depth := 0
+	var current strings.Builder
+
+	end := func() {
+		if current.Len() > 0 {
+			statements = append(statements, statement{start: start, text: strings.TrimSpace(current.String())})
+		}
+		current.Reset()
+		depth = 0
+	}
+
+	for offset, c := range text {
+		pos.Offset = offset
+		switch {
+		case c == ';' || (c == '\n' && depth == 0):
+			end()
+		case current.Len() == 0 && unicode.IsSpace(c):
+			// skip the whitespace before a statement
+		default:
+			if current.Len() == 0 {
+				start = pos
+			}
+			if c == '(' {
+				depth++
+			} else if c == ')' && depth > 0 {
+				depth--
+			}
+			current.WriteRune(c)
+		}
+
+		if c == '\n' {
+			pos.Line++
+			pos.Column = 1
+		} else {
+			pos.Column++
+		}
+	}
+	end()
+
+	return statements
+}
@@ -0,0 +1,237 @@
+package tok
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ExecuteProgram(t *testing.T) {
+	i := newTestInterpreter()
+	v, err := i.ExecuteProgram(`
+x = 2
+def double a = a * 2
+
+double(x) + 1`)
+	assert.NoError(t, err)
+	assert.Equal(t, 5, v)
+}
+
+func Test_ExecuteProgramWithSemicolons(t *testing.T) {
+	i := newTestInterpreter()
+	v, err := i.ExecuteProgram("x = 2; y = x * 3;; x + y;")
+	assert.NoError(t, err)
+	assert.Equal(t, 8, v)
+}
+
+func Test_ExecuteProgramContinuesInsideParens(t *testing.T) {
+	i := newTestInterpreter()
+	v, err := i.ExecuteProgram("x = (1 +\n  2)\nx * 2")
+	assert.NoError(t, err)
+	assert.Equal(t, 6, v)
+}
+
+func Test_ExecuteProgramResults(t *testing.T) {
+	i := newTestInterpreter()
+	results, err := i.ExecuteProgramResults("x = 2\n\ny = (x *\n 3); x + y")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"x = 2", "y = (x * 3)", "(x + y)"}, []string{
+		results[0].Node.String(), results[1].Node.String(), results[2].Node.String(),
+	})
+
+	// the nodes are checked above so that the rest of each result can be compared directly
+	for r := range results {
+		results[r].Node = nil
+	}
+	assert.Equal(t, []Result[int]{
+		{Line: 1, Text: "x = 2", Value: 2},
+		{Line: 3, Text: "y = (x *\n 3)", Value: 6},
+		{Line: 4, Text: "x + y", Value: 8},
+	}, results)
+}
+
+func Test_ExecuteProgramReportsFailingLine_IsError(t *testing.T) {
+	i := newTestInterpreter()
+	results, err := i.ExecuteProgramResults("x = 2\n\nx + y\nx * 3")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "line 3")
+	assert.Len(t, results, 1)
+}
+
+func Test_ExecuteEmptyProgram_IsError(t *testing.T) {
+	i := newTestInterpreter()
+	_, err := i.ExecuteProgram(" \n ; \n")
+	assert.Error(t, err)
+}
+
+func Test_ExecuteReader(t *testing.T) {
+	i := newTestInterpreter()
+	v, err := i.ExecuteReader(strings.NewReader("a = 4\nb = 5\na * b\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, 20, v)
+}
+
+func diagnosticStarts(diagnostics []*PositionError) []Position {
+	starts := make([]Position, len(diagnostics))
+	for i, d := range diagnostics {
+		starts[i] = d.Span.Start
+	}
+	return starts
+}
+
+func Test_ParseProgram(t *testing.T) {
+	i := newTestInterpreter()
+	nodes, diagnostics := i.ParseProgram("x = 2\ndef f a = a * x\nf(x) + 1")
+	assert.Empty(t, diagnostics)
+	assert.Equal(t, []string{"x = 2", "def f a = (a * x)", "(f(x) + 1)"}, []string{nodes[0].String(), nodes[1].String(), nodes[2].String()})
+}
+
+func Test_ParseProgramReportsEveryStatement_IsError(t *testing.T) {
+	i := newTestInterpreter()
+	nodes, diagnostics := i.ParseProgram("x = 1 +\ny = 2\nz = * 3; 4")
+	assert.Len(t, nodes, 2)
+	assert.Equal(t, []Position{
+		{Line: 1, Column: 8, Offset: 7},
+		{Line: 3, Column: 5, Offset: 18},
+	}, diagnosticStarts(diagnostics))
+}
+
+func Test_ParseProgramRecoversInsideArguments_IsError(t *testing.T) {
+	i := newTestInterpreter()
+	nodes, diagnostics := i.ParseProgram("f(1 +, 2 3, (4 *)) + (5 6)")
+	assert.Empty(t, nodes)
+	assert.Equal(t, []Position{
+		{Line: 1, Column: 6, Offset: 5},
+		{Line: 1, Column: 10, Offset: 9},
+		{Line: 1, Column: 17, Offset: 16},
+		{Line: 1, Column: 25, Offset: 24},
+	}, diagnosticStarts(diagnostics))
+
+	var syntaxErr *SyntaxError
+	for _, d := range diagnostics {
+		assert.True(t, errors.As(d, &syntaxErr))
+	}
+}
+
+func Test_ParseProgramWithoutSyncTokenStopsStatement_IsError(t *testing.T) {
+	i := newTestInterpreter()
+	_, diagnostics := i.ParseProgram("f(1 + \n2 * 3")
+	assert.Len(t, diagnostics, 1)
+}
+
+func Test_ParseStillStopsAtFirstError(t *testing.T) {
+	i := newTestInterpreter()
+	_, err := i.Parse("f(1 +, 2 3)")
+	assert.Error(t, err)
+	assert.Equal(t, Position{Line: 1, Column: 6, Offset: 5}, errorSpan(t, err).Start)
+}
+
+func Test_Compile(t *testing.T) {
+	i := newTestInterpreter()
+	_, err := i.Execute("rate = 3")
+	assert.NoError(t, err)
+
+	p, err := i.Compile("base = price * qty\nbase + rate")
+	assert.NoError(t, err)
+
+	for _, tc := range []struct {
+		vars     map[string]int
+		expected int
+	}{
+		{map[string]int{"price": 2, "qty": 5}, 13},
+		{map[string]int{"price": 4, "qty": 1}, 7},
+		{map[string]int{"price": 1, "qty": 1, "rate": 0}, 1},
+	} {
+		v, err := p.Eval(tc.vars)
+		assert.NoError(t, err)
+		assert.Equal(t, tc.expected, v)
+	}
+
+	// evaluating does not change the interpreter
+	assert.Equal(t, map[string]int{"rate": 3}, i.Variables())
+}
+
+func Test_CompiledDefinitionsAreLocal(t *testing.T) {
+	i := newTestInterpreter()
+	p, err := i.Compile("def double a = a * 2; double(x)")
+	assert.NoError(t, err)
+
+	v, err := p.Eval(map[string]int{"x": 21})
+	assert.NoError(t, err)
+	assert.Equal(t, 42, v)
+	assert.Empty(t, i.Functions())
+}
+
+func Test_CompiledDefinitionsAreDefinedOnce(t *testing.T) {
+	i := newTestInterpreter()
+	p, err := i.Compile("def double a = a * 2; def quad a = double(double(a)); quad(x)")
+	assert.NoError(t, err)
+
+	v, err := p.Eval(map[string]int{"x": 1})
+	assert.NoError(t, err)
+	assert.Equal(t, 4, v)
+	quad := p.functions[2]["quad"]
+
+	// evaluating again reuses the functions, which are only defined again when the
+	// functions of the Interpreter change
+	v, err = p.Eval(map[string]int{"x": 2})
+	assert.NoError(t, err)
+	assert.Equal(t, 8, v)
+	assert.Same(t, quad, p.functions[2]["quad"])
+
+	assert.NoError(t, i.RegisterFunc1("double", func(a int) (int, error) { return a * 3, nil }))
+	v, err = p.Eval(map[string]int{"x": 2})
+	assert.NoError(t, err)
+	assert.Equal(t, 8, v)
+	assert.NotSame(t, quad, p.functions[2]["quad"])
+
+	// a definition which fails is reported at its line every time
+	p, err = i.Compile("x = 1\ndef f a = a + y\nf(x)")
+	assert.NoError(t, err)
+	for n := 0; n < 2; n++ {
+		_, err = p.Eval(nil)
+		assert.EqualError(t, err, "line 2, column 15: could not find value for label: y")
+	}
+}
+
+func Test_Compile_IsError(t *testing.T) {
+	i := newTestInterpreter()
+	_, err := i.Compile("x = 1\ny = (2 +")
+	assert.EqualError(t, err, "line 2, column 9: expecting term, but none found")
+
+	_, err = i.Compile("")
+	assert.Error(t, err)
+
+	p, err := i.Compile("x = 1\nx + y")
+	assert.NoError(t, err)
+	_, err = p.Eval(nil)
+	assert.EqualError(t, err, "line 2, column 5: could not find value for label: y")
+}
+
+const benchmarkFormula = "price * qty + price * 2 * (qty + 1)"
+
+func BenchmarkExecute(b *testing.B) {
+	i := newTestInterpreter()
+	i.ExecuteProgram("price = 3; qty = 4")
+	for n := 0; n < b.N; n++ {
+		if _, err := i.Execute(benchmarkFormula); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkProgramEval(b *testing.B) {
+	i := newTestInterpreter()
+	p, err := i.Compile(benchmarkFormula)
+	if err != nil {
+		b.Fatal(err)
+	}
+	vars := map[string]int{"price": 3, "qty": 4}
+	for n := 0; n < b.N; n++ {
+		if _, err := p.Eval(vars); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
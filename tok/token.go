@@ -1,7 +1,6 @@
 package tok
 
 import (
-	"fmt"
 	"unicode"
 )
 
@@ -15,23 +14,71 @@ const (
 	labelType        tokenType = iota
 	assignmentOpType tokenType = iota
 	commaType        tokenType = iota
+	floatType        tokenType = iota
+	lambdaType       tokenType = iota
+	arrowType        tokenType = iota
 )
 
 type token struct {
 	value string
 	ty    tokenType
+	span  Span
 }
 
 type used struct{}
 
 type tokenizer struct {
 	operatorRuneSet map[rune]used
+	operatorTrie    *operatorTrie
 	operators       []string
 }
 
+// operatorTrie is a prefix tree of the operator symbols known to a tokenizer.
+// It is used to find the longest operator at the start of a sequence of runes.
+type operatorTrie struct {
+	children map[rune]*operatorTrie
+	terminal bool
+}
+
+func newOperatorTrie() *operatorTrie {
+	return &operatorTrie{children: make(map[rune]*operatorTrie)}
+}
+
+func (t *operatorTrie) insert(op string) {
+	node := t
+	for _, c := range op {
+		child, ok := node.children[c]
+		if !ok {
+			child = newOperatorTrie()
+			node.children[c] = child
+		}
+		node = child
+	}
+	node.terminal = true
+}
+
+// longestMatch returns the number of runes in the longest operator which is a
+// prefix of raw[start:] or 0 if no operator matches.
+func (t *operatorTrie) longestMatch(raw []rune, start int) int {
+	longest := 0
+	node := t
+	for pos := start; pos < len(raw); pos++ {
+		child, ok := node.children[raw[pos]]
+		if !ok {
+			break
+		}
+		node = child
+		if node.terminal {
+			longest = pos - start + 1
+		}
+	}
+	return longest
+}
+
 func newTokenizer(operators []string) tokenizer {
 	tokenizer := tokenizer{
 		operatorRuneSet: make(map[rune]used),
+		operatorTrie:    newOperatorTrie(),
 		operators:       operators,
 	}
 
@@ -40,6 +87,7 @@ func newTokenizer(operators []string) tokenizer {
 		for _, c := range []rune(op) {
 			tokenizer.operatorRuneSet[c] = used{}
 		}
+		tokenizer.operatorTrie.insert(op)
 	}
 
 	return tokenizer
@@ -47,6 +95,7 @@ func newTokenizer(operators []string) tokenizer {
 
 func (t *tokenizer) tokenize(text string) ([]token, error) {
 	raw := []rune(text)
+	positions := runePositions(text)
 	tokens := make([]token, 0)
 	// while not EOL
 	for currentChar := 0; currentChar < len(raw); {
@@ -57,25 +106,64 @@ func (t *tokenizer) tokenize(text string) ([]token, error) {
 		}
 
 		// create a new token
+		start := currentChar
 		var tok token
 		var err error
 		tok, currentChar, err = t.extractToken(raw, currentChar)
 		if err != nil {
-			return nil, err
+			return nil, &PositionError{
+				Span: Span{Start: positions[start], End: positions[start+1]},
+				Err:  err,
+			}
 		}
+		tok.span = Span{Start: positions[start], End: positions[currentChar]}
 		tokens = append(tokens, tok)
 	}
 
 	return tokens, nil
 }
 
+// runePositions finds the Position of each rune in text and of the end of text
+func runePositions(text string) []Position {
+	positions := make([]Position, 0, len(text)+1)
+	pos := Position{Line: 1, Column: 1}
+	for offset, c := range text {
+		pos.Offset = offset
+		positions = append(positions, pos)
+		if c == '\n' {
+			pos.Line++
+			pos.Column = 1
+		} else {
+			pos.Column++
+		}
+	}
+	pos.Offset = len(text)
+	return append(positions, pos)
+}
+
 func (t *tokenizer) extractToken(raw []rune, currentChar int) (tok token, charPos int, err error) {
 	// Check the current char to determine what type of token this is
-	// if char is digit then extract integer token
+	// if char is digit then extract integer or float token
 	if unicode.IsDigit(raw[currentChar]) {
-		return t.extractIntToken(raw, currentChar)
+		return t.extractNumberToken(raw, currentChar)
 	} else if unicode.IsLetter(raw[currentChar]) {
 		return t.extractLabelToken(raw, currentChar)
+	} else if raw[currentChar] == '=' && t.operatorTrie.longestMatch(raw, currentChar) == 0 {
+		// a lone '=' is assignment but it may also begin an operator such as '=='
+		return token{
+			value: "=",
+			ty:    assignmentOpType,
+		}, currentChar + 1, nil
+	} else if t.isArrow(raw, currentChar) {
+		return token{
+			value: "->",
+			ty:    arrowType,
+		}, currentChar + 2, nil
+	} else if raw[currentChar] == '\\' {
+		return token{
+			value: "\\",
+			ty:    lambdaType,
+		}, currentChar + 1, nil
 	} else if _, ok := t.operatorRuneSet[raw[currentChar]]; ok {
 		// if char is not then consume operator
 		return t.extractOperatorToken(raw, currentChar)
@@ -89,52 +177,86 @@ func (t *tokenizer) extractToken(raw []rune, currentChar int) (tok token, charPo
 			value: ")",
 			ty:    rParen,
 		}, currentChar + 1, nil
-	} else if raw[currentChar] == '=' {
-		return token{
-			value: "=",
-			ty:    assignmentOpType,
-		}, currentChar + 1, nil
 	} else if raw[currentChar] == ',' {
 		return token{
 			value: ",",
 			ty:    commaType,
 		}, currentChar + 1, nil
 	} else {
-		return token{}, -1, fmt.Errorf("unexpected character during tokenization: %s", string(raw[currentChar]))
+		return token{}, -1, syntaxErrorf("unexpected character during tokenization: %s", string(raw[currentChar]))
 	}
 }
 
-func (t *tokenizer) extractIntToken(raw []rune, currentChar int) (tok token, charPos int, err error) {
-	for charPos = currentChar; charPos < len(raw) && unicode.IsDigit(raw[charPos]); charPos++ {
+// isArrow is true if the runes at currentChar are '->' and are not the start of an
+// operator which is at least as long
+func (t *tokenizer) isArrow(raw []rune, currentChar int) bool {
+	return currentChar+1 < len(raw) && raw[currentChar] == '-' && raw[currentChar+1] == '>' &&
+		t.operatorTrie.longestMatch(raw, currentChar) < 2
+}
+
+// extractNumberToken consumes an integer or, if it is followed by a fraction or an
+// exponent, a float
+func (t *tokenizer) extractNumberToken(raw []rune, currentChar int) (tok token, charPos int, err error) {
+	ty := intType
+	charPos = skipDigits(raw, currentChar)
+
+	// a fraction must have at least one digit after the decimal point
+	if charPos+1 < len(raw) && raw[charPos] == '.' && unicode.IsDigit(raw[charPos+1]) {
+		ty = floatType
+		charPos = skipDigits(raw, charPos+1)
+	}
+
+	// an exponent must have at least one digit after the optional sign
+	if charPos < len(raw) && (raw[charPos] == 'e' || raw[charPos] == 'E') {
+		exp := charPos + 1
+		if exp < len(raw) && (raw[exp] == '+' || raw[exp] == '-') {
+			exp++
+		}
+		if exp < len(raw) && unicode.IsDigit(raw[exp]) {
+			ty = floatType
+			charPos = skipDigits(raw, exp)
+		}
 	}
 
 	tok = token{
 		value: string(raw[currentChar:charPos]),
-		ty:    intType,
+		ty:    ty,
 	}
 
 	return tok, charPos, nil
 }
 
+func skipDigits(raw []rune, charPos int) int {
+	for ; charPos < len(raw) && unicode.IsDigit(raw[charPos]); charPos++ {
+	}
+	return charPos
+}
+
+// extractOperatorToken consumes the longest registered operator which starts at currentChar
 func (t *tokenizer) extractOperatorToken(raw []rune, currentChar int) (tok token, newCharPos int, err error) {
-	charPos := currentChar
-	for ; charPos < len(raw); charPos++ {
-		if _, ok := t.operatorRuneSet[raw[charPos]]; !ok {
-			break
+	length := t.operatorTrie.longestMatch(raw, currentChar)
+	if length == 0 {
+		// report the whole run of operator characters which could not be matched
+		charPos := currentChar
+		for ; charPos < len(raw); charPos++ {
+			if _, ok := t.operatorRuneSet[raw[charPos]]; !ok {
+				break
+			}
 		}
+		return token{}, -1, syntaxErrorf("unknown operator: %s", string(raw[currentChar:charPos]))
 	}
 
 	tok = token{
-		value: string(raw[currentChar:charPos]),
+		value: string(raw[currentChar : currentChar+length]),
 		ty:    operatorType,
 	}
 
-	return tok, charPos, nil
+	return tok, currentChar + length, nil
 }
 
 func (t *tokenizer) extractLabelToken(raw []rune, currentChar int) (tok token, newCharPos int, err error) {
 	if !unicode.IsLetter(raw[currentChar]) {
-		return token{}, currentChar, fmt.Errorf("`label` must start with letter")
+		return token{}, currentChar, syntaxErrorf("`label` must start with letter")
 	}
 
 	start := currentChar
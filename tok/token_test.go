@@ -11,7 +11,7 @@ func Test_WhiteSpaceAtEnd(t *testing.T) {
 	tokenizer := newTokenizer([]string{})
 	tokens, err := tokenizer.tokenize(text)
 	assert.NoError(t, err)
-	assert.Equal(t, token{value: "2", ty: intType}, tokens[0])
+	assert.Equal(t, token{value: "2", ty: intType}, withoutSpans(tokens)[0])
 }
 
 func Test_WhiteSpaceAtStart(t *testing.T) {
@@ -19,7 +19,7 @@ func Test_WhiteSpaceAtStart(t *testing.T) {
 	tokenizer := newTokenizer([]string{})
 	tokens, err := tokenizer.tokenize(text)
 	assert.NoError(t, err)
-	assert.Equal(t, token{value: "2", ty: intType}, tokens[0])
+	assert.Equal(t, token{value: "2", ty: intType}, withoutSpans(tokens)[0])
 }
 
 func Test_WhiteSpaceBothEnds(t *testing.T) {
@@ -27,5 +27,124 @@ func Test_WhiteSpaceBothEnds(t *testing.T) {
 	tokenizer := newTokenizer([]string{})
 	tokens, err := tokenizer.tokenize(text)
 	assert.NoError(t, err)
-	assert.Equal(t, token{value: "2", ty: intType}, tokens[0])
+	assert.Equal(t, token{value: "2", ty: intType}, withoutSpans(tokens)[0])
+}
+
+func Test_AdjacentOperatorsAreSplit(t *testing.T) {
+	tokenizer := newTokenizer([]string{"*", "-"})
+	tokens, err := tokenizer.tokenize("2*-3")
+	assert.NoError(t, err)
+	assert.Equal(t, []token{
+		{value: "2", ty: intType},
+		{value: "*", ty: operatorType},
+		{value: "-", ty: operatorType},
+		{value: "3", ty: intType},
+	}, withoutSpans(tokens))
+}
+
+func Test_LongestOperatorIsMatchedFirst(t *testing.T) {
+	tokenizer := newTokenizer([]string{"-", "--", "**", "*"})
+	tokens, err := tokenizer.tokenize("---x**-2")
+	assert.NoError(t, err)
+
+	values := make([]string, 0, len(tokens))
+	for _, tok := range tokens {
+		values = append(values, tok.value)
+	}
+	assert.Equal(t, []string{"--", "-", "x", "**", "-", "2"}, values)
+}
+
+func Test_OperatorPrefixIsNotAnOperator(t *testing.T) {
+	tokenizer := newTokenizer([]string{"<*>", "<"})
+	tokens, err := tokenizer.tokenize("1<*2")
+	assert.Error(t, err)
+	assert.Nil(t, tokens)
+
+	tokens, err = tokenizer.tokenize("1<2<*>3")
+	assert.NoError(t, err)
+	assert.Equal(t, "<", tokens[1].value)
+	assert.Equal(t, "<*>", tokens[3].value)
+}
+
+func Test_UnknownOperatorSequence_IsError(t *testing.T) {
+	tokenizer := newTokenizer([]string{"+-", "*"})
+	_, err := tokenizer.tokenize("2 -+ 3")
+	assert.EqualError(t, err, "line 1, column 3: unknown operator: -+")
+}
+
+func Test_EqualsIsAssignmentUnlessPartOfAnOperator(t *testing.T) {
+	tokenizer := newTokenizer([]string{"==", "<=", "<", "=>"})
+	tokens, err := tokenizer.tokenize("x = 1 == 2 <= 3 < 4=>5")
+	assert.NoError(t, err)
+	assert.Equal(t, []token{
+		{value: "x", ty: labelType},
+		{value: "=", ty: assignmentOpType},
+		{value: "1", ty: intType},
+		{value: "==", ty: operatorType},
+		{value: "2", ty: intType},
+		{value: "<=", ty: operatorType},
+		{value: "3", ty: intType},
+		{value: "<", ty: operatorType},
+		{value: "4", ty: intType},
+		{value: "=>", ty: operatorType},
+		{value: "5", ty: intType},
+	}, withoutSpans(tokens))
+}
+
+func Test_FloatLiterals(t *testing.T) {
+	tokenizer := newTokenizer([]string{"-", "+"})
+	for input, expected := range map[string]token{
+		"1.5":     {value: "1.5", ty: floatType},
+		"1e-3":    {value: "1e-3", ty: floatType},
+		"2.5E+10": {value: "2.5E+10", ty: floatType},
+		"3e2":     {value: "3e2", ty: floatType},
+		"42":      {value: "42", ty: intType},
+	} {
+		tokens, err := tokenizer.tokenize(input)
+		assert.NoError(t, err, input)
+		assert.Equal(t, []token{expected}, withoutSpans(tokens), input)
+	}
+}
+
+func Test_IncompleteFloatIsNotConsumed(t *testing.T) {
+	tokenizer := newTokenizer([]string{"-"})
+	tokens, err := tokenizer.tokenize("2e-x")
+	assert.NoError(t, err)
+	assert.Equal(t, []token{
+		{value: "2", ty: intType},
+		{value: "e", ty: labelType},
+		{value: "-", ty: operatorType},
+		{value: "x", ty: labelType},
+	}, withoutSpans(tokens))
+}
+
+// withoutSpans clears the positions of tokens so that tests can compare only their values
+func withoutSpans(tokens []token) []token {
+	stripped := make([]token, len(tokens))
+	for i, tok := range tokens {
+		stripped[i] = token{value: tok.value, ty: tok.ty}
+	}
+	return stripped
+}
+
+func Test_TokenSpans(t *testing.T) {
+	tokenizer := newTokenizer([]string{"+"})
+	tokens, err := tokenizer.tokenize("ab + 1.5\n  é+x")
+	assert.NoError(t, err)
+	assert.Equal(t, []Span{
+		{Start: Position{Line: 1, Column: 1, Offset: 0}, End: Position{Line: 1, Column: 3, Offset: 2}},
+		{Start: Position{Line: 1, Column: 4, Offset: 3}, End: Position{Line: 1, Column: 5, Offset: 4}},
+		{Start: Position{Line: 1, Column: 6, Offset: 5}, End: Position{Line: 1, Column: 9, Offset: 8}},
+		{Start: Position{Line: 2, Column: 3, Offset: 11}, End: Position{Line: 2, Column: 4, Offset: 13}},
+		{Start: Position{Line: 2, Column: 4, Offset: 13}, End: Position{Line: 2, Column: 5, Offset: 14}},
+		{Start: Position{Line: 2, Column: 5, Offset: 14}, End: Position{Line: 2, Column: 6, Offset: 15}},
+	}, spansOf(tokens))
+}
+
+func spansOf(tokens []token) []Span {
+	spans := make([]Span, len(tokens))
+	for i, tok := range tokens {
+		spans[i] = tok.span
+	}
+	return spans
 }
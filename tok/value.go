@@ -0,0 +1,108 @@
+package tok
+
+import (
+	"errors"
+)
+
+// value is the result of evaluating a node: either a value from the domain or, when
+// fn is set, a function
+type value[T any] struct {
+	v  T
+	fn callable[T]
+}
+
+// scalar wraps the result of a computation on the domain as a value
+func scalar[T any](v T, err error) (value[T], error) {
+	return value[T]{v: v}, err
+}
+
+// callable is anything which can be called: a function defined with def or registered
+// from Go, or a closure created by a lambda
+type callable[T any] interface {
+	apply(caller *Interpreter[T], args []value[T]) (value[T], error)
+	String() string
+}
+
+// scope is a set of bindings and the scope which encloses it.  Labels which are not
+// found in a scope are looked up in its parent.
+type scope[T any] struct {
+	vars   map[string]value[T]
+	parent *scope[T]
+}
+
+// lookup finds label in s or the scopes which enclose it
+func (s *scope[T]) lookup(label string) (value[T], bool) {
+	for ; s != nil; s = s.parent {
+		if v, ok := s.vars[label]; ok {
+			return v, true
+		}
+	}
+	return value[T]{}, false
+}
+
+// closure is a lambda together with the bindings which were in scope where it was
+// evaluated.  It is only used for a lambda whose body cannot be compiled, which is
+// evaluated by walking the tree.
+type closure[T any] struct {
+	lambda Lambda
+	env    *scope[T]
+}
+
+func (c *closure[T]) String() string {
+	return c.lambda.String()
+}
+
+// apply evaluates the body of the lambda with its parameters bound in a new scope
+// whose parent is the scope the closure captured
+func (c *closure[T]) apply(caller *Interpreter[T], args []value[T]) (value[T], error) {
+	if len(args) != len(c.lambda.Params) {
+		return value[T]{}, &ArityError{Function: "lambda", Expected: len(c.lambda.Params), Got: len(args)}
+	}
+	if caller.depth >= maxCallDepth {
+		return value[T]{}, &CallDepthError{Function: "lambda", Depth: maxCallDepth}
+	}
+
+	interpreter := *caller
+	interpreter.labelBindings = make(map[string]value[T], len(args))
+	interpreter.outer = c.env
+	interpreter.depth++
+	for i, label := range c.lambda.Params {
+		interpreter.labelBindings[label] = args[i]
+	}
+
+	return interpreter.evalNode(c.lambda.Body)
+}
+
+// call applies fn to args.  An error in the body of the function is reported at span,
+// the position of the call, because the position it has refers to the text where the
+// function was defined.
+func (i *Interpreter[T]) call(fn callable[T], args []value[T], span Span) (value[T], error) {
+	v, err := fn.apply(i, args)
+	return v, reportAtCall(err, span)
+}
+
+// reportAtCall moves an error from the body of a function to span, the position of the
+// call
+func reportAtCall(err error, span Span) error {
+	if err == nil {
+		return nil
+	}
+	var pe *PositionError
+	if errors.As(err, &pe) {
+		return &PositionError{Span: span, Err: pe.Err}
+	}
+	return err
+}
+
+// evalScalar evaluates node and fails if the result is a function, for the places
+// which need a value from the domain such as the operands of an operator
+func (i *Interpreter[T]) evalScalar(node Node) (T, error) {
+	v, err := i.evalNode(node)
+	if err != nil {
+		return v.v, err
+	}
+	if v.fn != nil {
+		return v.v, errorAt(node.Pos(), &FunctionValueError{Function: v.fn.String()})
+	}
+	return v.v, nil
+}
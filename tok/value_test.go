@@ -0,0 +1,86 @@
+package tok
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Lambdas(t *testing.T) {
+	for input, expected := range map[string]int{
+		"(\\x -> x + 1)(2)":                                                       3,
+		"(fun x y -> x * y)(3, 4)":                                                12,
+		"(\\ -> 7)()":                                                             7,
+		"k = 10; add = \\x -> x + k; add(1)":                                      11,
+		"def adder k = \\x -> x + k; adder(3)(4)":                                 7,
+		"def twice f x = f(f(x)); twice(\\x -> x * 2, 3)":                         12,
+		"def inc x = x + 1; def twice f x = f(f(x)); twice(inc, 0)":               2,
+		"def adder k = \\x -> x + k; def twice f x = f(f(x)); twice(adder(5), 1)": 11,
+		"(\\x -> \\y -> x - y)(10)(3)":                                            7,
+		"f = \\x -> if x then 1 else 2; f(0)":                                     2,
+	} {
+		i := newTestInterpreter()
+		v, err := i.ExecuteProgram(input)
+		assert.NoError(t, err, input)
+		assert.Equal(t, expected, v, input)
+	}
+}
+
+func Test_ClosuresCaptureParameters(t *testing.T) {
+	i := newTestInterpreter()
+	v, err := i.ExecuteProgram(`
+def adder k = \x -> x + k
+add2 = adder(2)
+add5 = adder(5)
+add2(1) * 10 + add5(1)`)
+	assert.NoError(t, err)
+	assert.Equal(t, 36, v)
+}
+
+func Test_ParametersShadowFunctions(t *testing.T) {
+	i := newTestInterpreter()
+	v, err := i.ExecuteProgram("def f x = x + 100; def g f = f(1); g(\\x -> x)")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, v)
+}
+
+func Test_FunctionValuesAreNotVariables(t *testing.T) {
+	i := newTestInterpreter()
+	v, err := i.ExecuteProgram("a = 1; f = \\x -> x")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, v)
+	assert.Equal(t, map[string]int{"a": 1}, i.Variables())
+}
+
+func Test_Lambdas_IsError(t *testing.T) {
+	for input, target := range map[string]interface{}{
+		"(\\x -> x) + 1":           new(*FunctionValueError),
+		"f = \\x -> x; f(1, 2)":    new(*ArityError),
+		"x = 1; x(2)":              new(*UndefinedFunctionError),
+		"(1 + 2)(3)":               new(*NotAFunctionError),
+		"def f x = \\y -> z; f(1)": new(*UndefinedLabelError),
+		"def f x = (\\y -> y)(y)":  new(*UndefinedLabelError),
+		"(\\x -> x +)(1)":          new(*SyntaxError),
+		"\\x x + 1":                new(*SyntaxError),
+	} {
+		i := newTestInterpreter()
+		_, err := i.ExecuteProgram(input)
+		assert.Error(t, err, input)
+		assert.True(t, errors.As(err, target), "%s: %v", input, err)
+	}
+}
+
+func Test_ParseLambda(t *testing.T) {
+	i := newTestInterpreter()
+	for input, expected := range map[string]string{
+		"\\x y -> x + y": "(\\ x y -> (x + y))",
+		"fun -> 1":       "(\\ -> 1)",
+		"f(1)(2, 3)":     "f(1)(2, 3)",
+		"(\\x -> x)(1)":  "(\\ x -> x)(1)",
+	} {
+		node, err := i.Parse(input)
+		assert.NoError(t, err, input)
+		assert.Equal(t, expected, node.String(), input)
+	}
+}
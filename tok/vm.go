@@ -0,0 +1,284 @@
+package tok
+
+// vm runs chunks on a stack of values.  A call pushes a frame: the parameters of the
+// callee are the arguments its caller pushed and its lets take the slots above them, so
+// calls between compiled functions and closures neither copy the Interpreter nor allocate.
+type vm[T any] struct {
+	interpreter *Interpreter[T]
+	depth       int
+	stack       []value[T]
+}
+
+// frame is where the chunk which is running finds its labels.  Its parameters and lets
+// are in the stack starting at base and the values captured by its closure are in
+// captured.  env is the scope of a closure created outside of any function, in which
+// its other labels are looked up; without one they are looked up in the Interpreter.
+type frame[T any] struct {
+	base     int
+	captured []value[T]
+	env      *scope[T]
+}
+
+// execute runs a compiled statement
+func (i *Interpreter[T]) execute(c *chunk[T]) (value[T], error) {
+	m := vm[T]{interpreter: i, depth: i.depth, stack: make([]value[T], 0, 16)}
+	return m.run(c, frame[T]{})
+}
+
+// applyCompiled runs the compiled body of f with params as its parameters
+func (f *function[T]) applyCompiled(caller *Interpreter[T], params []value[T]) (value[T], error) {
+	return runFrame(caller, f.code, params, frame[T]{})
+}
+
+// runFrame runs code for a call from outside of the vm, with params in the first slots
+func runFrame[T any](caller *Interpreter[T], code *chunk[T], params []value[T], f frame[T]) (value[T], error) {
+	m := vm[T]{interpreter: caller, depth: caller.depth + 1, stack: make([]value[T], len(params), len(params)+16)}
+	copy(m.stack, params)
+	return m.run(code, f)
+}
+
+// compiledClosure is a closure whose lambda was compiled.  Instead of the scope around
+// the lambda it holds the values of the labels the lambda captured and, if the lambda
+// is outside of any function, the scope where it was created.
+type compiledClosure[T any] struct {
+	lambda   Lambda
+	code     *chunk[T]
+	captured []value[T]
+	env      *scope[T]
+}
+
+func (c *compiledClosure[T]) String() string {
+	return c.lambda.String()
+}
+
+func (c *compiledClosure[T]) check(args, depth int) error {
+	if args != len(c.lambda.Params) {
+		return &ArityError{Function: "lambda", Expected: len(c.lambda.Params), Got: args}
+	}
+	if depth >= maxCallDepth {
+		return &CallDepthError{Function: "lambda", Depth: maxCallDepth}
+	}
+	return nil
+}
+
+func (c *compiledClosure[T]) apply(caller *Interpreter[T], args []value[T]) (value[T], error) {
+	if err := c.check(len(args), caller.depth); err != nil {
+		return value[T]{}, err
+	}
+	return runFrame(caller, c.code, args, frame[T]{captured: c.captured, env: c.env})
+}
+
+func (m *vm[T]) push(v value[T]) {
+	m.stack = append(m.stack, v)
+}
+
+func (m *vm[T]) pop() value[T] {
+	v := m.stack[len(m.stack)-1]
+	m.stack = m.stack[:len(m.stack)-1]
+	return v
+}
+
+// run executes c in the frame fr.  Every error is reported at the position of the node
+// of the instruction which failed.
+func (m *vm[T]) run(c *chunk[T], fr frame[T]) (value[T], error) {
+	i := m.interpreter
+	for pc := 0; pc < len(c.code); pc++ {
+		ins := &c.code[pc]
+		switch ins.op {
+		case opConst:
+			m.push(c.consts[ins.arg])
+		case opLoad:
+			v, err := m.lookupLabel(c.names[ins.arg], fr.env)
+			if err != nil {
+				return v, errorAt(ins.node.Pos(), err)
+			}
+			m.push(v)
+		case opLocal:
+			m.push(m.stack[fr.base+ins.arg])
+		case opCaptured:
+			m.push(fr.captured[ins.arg])
+		case opFunction:
+			f, ok := i.funcBindings[c.names[ins.arg]]
+			if !ok {
+				return value[T]{}, errorAt(ins.node.Pos(), &UndefinedLabelError{Name: c.names[ins.arg]})
+			}
+			m.push(value[T]{fn: f.asValue()})
+		case opStore:
+			i.labelBindings[c.names[ins.arg]] = m.stack[len(m.stack)-1]
+		case opDefine:
+			if err := i.defineFunction(ins.node.(FuncDef)); err != nil {
+				return value[T]{}, errorAt(ins.node.Pos(), err)
+			}
+			m.push(value[T]{})
+		case opScalar:
+			if v := m.stack[len(m.stack)-1]; v.fn != nil {
+				return value[T]{}, errorAt(ins.node.Pos(), &FunctionValueError{Function: v.fn.String()})
+			}
+		case opUnary:
+			op, ok := i.unaryOps[c.names[ins.arg]]
+			if !ok {
+				return value[T]{}, errorAt(ins.node.Pos(), &UndefinedOperatorError{Op: c.names[ins.arg]})
+			}
+			a := m.pop()
+			v, err := applyOperator(c.names[ins.arg], func() (T, error) { return op.apply(a.v) })
+			if err != nil {
+				return value[T]{}, errorAt(ins.node.Pos(), err)
+			}
+			m.push(value[T]{v: v})
+		case opBinary:
+			op, ok := i.binaryOps[c.names[ins.arg]]
+			if !ok {
+				return value[T]{}, errorAt(ins.node.Pos(), &UndefinedOperatorError{Op: c.names[ins.arg]})
+			}
+			b := m.pop()
+			a := m.pop()
+			v, err := applyOperator(c.names[ins.arg], func() (T, error) { return op.apply(a.v, b.v) })
+			if err != nil {
+				return value[T]{}, errorAt(ins.node.Pos(), err)
+			}
+			m.push(value[T]{v: v})
+		case opNot:
+			m.push(value[T]{v: i.domain.FromBool(!i.domain.Truthy(m.pop().v))})
+		case opBool:
+			m.push(value[T]{v: i.domain.FromBool(i.domain.Truthy(m.pop().v))})
+		case opTrue:
+			m.push(value[T]{v: i.domain.FromBool(true)})
+		case opFalse:
+			m.push(value[T]{v: i.domain.FromBool(false)})
+		case opJump:
+			pc = ins.arg - 1
+		case opJumpIfFalse:
+			if !i.domain.Truthy(m.pop().v) {
+				pc = ins.arg - 1
+			}
+		case opJumpIfTrue:
+			if i.domain.Truthy(m.pop().v) {
+				pc = ins.arg - 1
+			}
+		case opResolve, opResolveLocal, opResolveCaptured, opResolveFunc:
+			fn, err := m.resolve(ins, c.names[ins.arg], fr)
+			if err != nil {
+				return value[T]{}, errorAt(ins.node.Pos(), err)
+			}
+			m.push(value[T]{fn: fn})
+		case opCallable:
+			if f := m.stack[len(m.stack)-1]; f.fn == nil {
+				return value[T]{}, errorAt(ins.node.Pos(), &NotAFunctionError{Expr: ins.node.String()})
+			}
+		case opClosure:
+			values := make([]value[T], ins.argc)
+			copy(values, m.stack[len(m.stack)-ins.argc:])
+			m.stack = m.stack[:len(m.stack)-ins.argc]
+			lambda := &c.lambdas[ins.arg]
+			closure := &compiledClosure[T]{lambda: lambda.lambda, code: lambda.code, captured: values}
+			if lambda.scoped {
+				closure.env = fr.env
+				if closure.env == nil {
+					closure.env = &scope[T]{vars: i.labelBindings, parent: i.outer}
+				}
+			}
+			m.push(value[T]{fn: closure})
+		case opSlide:
+			v := m.pop()
+			m.stack = m.stack[:len(m.stack)-ins.arg]
+			m.push(v)
+		case opApply:
+			callee := len(m.stack) - ins.argc - 1
+			v, err := m.apply(m.stack[callee].fn, callee, ins.node.Pos())
+			if err != nil {
+				return value[T]{}, errorAt(ins.node.Pos(), err)
+			}
+			m.stack = m.stack[:callee]
+			m.push(v)
+		}
+	}
+
+	return m.stack[len(m.stack)-1], nil
+}
+
+// lookupLabel finds the value of a label in env or, if there is no env, in the
+// Interpreter.  As with Interpreter.lookupLabel it may name a function.
+func (m *vm[T]) lookupLabel(name string, env *scope[T]) (value[T], error) {
+	if env == nil {
+		return m.interpreter.lookupLabel(name)
+	}
+	if v, ok := env.lookup(name); ok {
+		return v, nil
+	}
+	if f, ok := m.interpreter.funcBindings[name]; ok {
+		return value[T]{fn: f.asValue()}, nil
+	}
+	return value[T]{}, &UndefinedLabelError{Name: name}
+}
+
+func (m *vm[T]) lookupBinding(name string, env *scope[T]) (value[T], bool) {
+	if env == nil {
+		return m.interpreter.lookupBinding(name)
+	}
+	return env.lookup(name)
+}
+
+// resolve finds the function called by a Call.  As when walking the tree a variable or
+// parameter which is bound to a function is preferred to a function of the same name.
+func (m *vm[T]) resolve(ins *instruction, name string, fr frame[T]) (callable[T], error) {
+	switch ins.op {
+	case opResolve:
+		if v, ok := m.lookupBinding(name, fr.env); ok && v.fn != nil {
+			return v.fn, nil
+		}
+	case opResolveLocal:
+		if v := m.stack[fr.base+ins.argc]; v.fn != nil {
+			return v.fn, nil
+		}
+	case opResolveCaptured:
+		if v := fr.captured[ins.argc]; v.fn != nil {
+			return v.fn, nil
+		}
+	}
+
+	f, ok := m.interpreter.funcBindings[name]
+	if !ok {
+		return nil, &UndefinedFunctionError{Name: name}
+	}
+	return f, nil
+}
+
+// apply calls fn with the values above it in the stack, which starts at callee.  A
+// compiled function or closure runs on this stack; anything else is called as when
+// walking the tree.
+func (m *vm[T]) apply(fn callable[T], callee int, span Span) (value[T], error) {
+	args := m.stack[callee+1:]
+	var code *chunk[T]
+	next := frame[T]{base: callee + 1}
+	switch f := fn.(type) {
+	case *function[T]:
+		if f.code != nil {
+			if len(args) != len(f.parameters) {
+				return value[T]{}, &ArityError{Function: f.name, Expected: len(f.parameters), Got: len(args)}
+			}
+			if m.depth >= maxCallDepth {
+				return value[T]{}, &CallDepthError{Function: f.name, Depth: maxCallDepth}
+			}
+			code = f.code
+		}
+	case *compiledClosure[T]:
+		if err := f.check(len(args), m.depth); err != nil {
+			return value[T]{}, err
+		}
+		code, next.captured, next.env = f.code, f.captured, f.env
+	}
+	if code == nil {
+		// the callee sees the depth of this frame
+		depth := m.interpreter.depth
+		m.interpreter.depth = m.depth
+		v, err := m.interpreter.call(fn, args, span)
+		m.interpreter.depth = depth
+		return v, err
+	}
+
+	m.depth++
+	v, err := m.run(code, next)
+	m.depth--
+
+	return v, reportAtCall(err, span)
+}
@@ -0,0 +1,227 @@
+package tok
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// vmPrograms are run both by the vm and by walking the tree, which must agree on every
+// result and error
+var vmPrograms = []string{
+	"1 + 2 * 3",
+	"-(4 - 6) * 2",
+	"x = 5; y = x * x; y - x",
+	"def fact n = if n <= 1 then 1 else n * fact(n - 1); fact(10)",
+	"def fib n = if n < 2 then n else fib(n - 1) + fib(n - 2); fib(15)",
+	"def f a b = a - b; f(10, 2) * f(1, 3)",
+	"1 < 2 and 3 or 0",
+	"0 and undefined",
+	"1 or undefined",
+	"not 0 + not 1",
+	"def inc x = x + 1; def dbl x = inc(x) + inc(x) - 2; dbl(5)",
+	"def g x = x + 1; def twice f x = f(f(x)); twice(g, 1)",
+	"def g x = x + 1; h = g; h(2)",
+	"def adder k = \\x -> x + k; adder(2)(3)",
+	"def f x = sub(x, 1); f(10)",
+	"def f x = x + 1; def f x = x * 10; f(3)",
+	"1 / 0",
+	"def f x = 1 / x; 1 + f(0)",
+	"def f x = f(x); f(1)",
+	"def f x = x; f(1, 2)",
+	"def f x = x; f + 1",
+	"def f x = x; if f then 1 else 2",
+	"x = 1; x(2)",
+	"undefined + 1",
+	"g(1)",
+	"sub(1)",
+	"def g x = x + 1; g(1)(2)",
+	"def h f = f(1); h(2)",
+	"def h f = f + 1; def g x = x; h(g)",
+	"def add a = \\b -> \\c -> a + b + c; add(1)(2)(3)",
+	"def f n = let k = n * 2 in (\\x -> x + k + n)(1); f(5)",
+	"def g x = x * 3; def f h = (\\x -> h(x) + g(x))(2); f(\\y -> y - 1)",
+	"k = 10; f = \\x -> x + k; f(1)",
+	"def f x = (\\a b -> a)(x); f(1)",
+	"def f x = (\\y -> f(y))(x); f(1)",
+	"def f x = (\\y -> y)(x) + (\\y -> y); f(1)",
+	"(let a = 2 in \\x -> x * a)(4)",
+	"(0 * 0)(1)",
+	"(if 1 < 2 then 3 - 3 else 1)(1)",
+	"f = \\x -> x * (2 * 3) + (if 1 then x else 0); f + 1",
+	"if 0 then undefined else 2",
+	"k = 1; f = \\x -> x + k; k = 2; f(0)",
+	"k = 3; g = \\x -> h(x) + k; def h x = x * 2; g(1)",
+	"f = \\n -> if n < 1 then 0 else n + f(n - 1); f(10)",
+	"f = \\x -> let y = x * 2 in \\z -> y + z + x; f(1)(2)",
+	"def twice f x = f(f(x)); twice(\\x -> x + 1, 1)",
+	"f = \\x -> x + undefined; f(1)",
+	"(\\x -> x)(1, 2)",
+	"f = \\x -> x; f + 1",
+	"def g x = x + 1; def f x = g(x) * 2; h = f; def g x = x + 10; h(1)",
+	"def g x = x + 1; def f x = g(x) * 2; h = f; def f x = 0; def g x = x + 10; h(1)",
+}
+
+// evalCompiled compiles a program and evaluates it
+func evalCompiled(i *IntInterpreter, program string) (int, error) {
+	p, err := i.Compile(program)
+	if err != nil {
+		return 0, err
+	}
+	return p.Eval(nil)
+}
+
+func Test_VMAgreesWithTreeWalk(t *testing.T) {
+	for _, program := range vmPrograms {
+		vm := newTestInterpreter()
+		walk := newTestInterpreter()
+		walk.walk = true
+
+		// a compiled program runs its statements on the vm, while ExecuteProgram only
+		// runs the functions they call on it and binds the variables
+		for _, run := range []func(i *IntInterpreter) (int, error){
+			func(i *IntInterpreter) (int, error) { return evalCompiled(i, program) },
+			func(i *IntInterpreter) (int, error) { return i.ExecuteProgram(program) },
+		} {
+			expected, expectedErr := run(&walk)
+			v, err := run(&vm)
+			assert.Equal(t, expected, v, program)
+			if expectedErr == nil {
+				assert.NoError(t, err, program)
+			} else {
+				assert.EqualError(t, err, expectedErr.Error(), program)
+			}
+		}
+		assert.Equal(t, walk.Variables(), vm.Variables(), program)
+	}
+}
+
+func Test_CompileLambdas(t *testing.T) {
+	i := newTestInterpreter()
+	for input, compiled := range map[string]bool{
+		"1 + x":                  true,
+		"def f x = x * f(x)":     true,
+		"(\\x -> x)(1)":          true,
+		"let a = 1 in a":         true,
+		"def f x = \\y -> x + y": true,
+	} {
+		node, err := i.Parse(input)
+		assert.NoError(t, err, input)
+		assert.Equal(t, compiled, i.compile(node) != nil, input)
+	}
+
+	_, err := i.Execute("def f x = \\y -> x + y")
+	assert.NoError(t, err)
+	assert.NotNil(t, i.funcBindings["f"].code)
+}
+
+func Test_CallsDoNotAllocate(t *testing.T) {
+	i := newTestInterpreter()
+	_, err := i.ExecuteProgram(benchmarkFib + "; def apply f x = f(x); def fibs n = apply(\\k -> fib(k + n), 1)")
+	assert.NoError(t, err)
+
+	allocs := func(input string) float64 {
+		node, err := i.Parse(input)
+		assert.NoError(t, err)
+		return testing.AllocsPerRun(10, func() {
+			i.Eval(node)
+		})
+	}
+
+	// fib(15) makes almost 2000 calls more than fib(1) and only grows the stack, while
+	// fibs also creates a closure
+	one := allocs("fib(1)")
+	assert.LessOrEqual(t, allocs("fib(15)")-one, float64(2))
+	assert.LessOrEqual(t, allocs("fibs(14)")-one, float64(5))
+
+	// a lambda in a statement which is walked is compiled once and called in frames, so
+	// twice the calls only grow the stack once more
+	_, err = i.Execute(benchmarkLoop)
+	assert.NoError(t, err)
+	assert.LessOrEqual(t, allocs("loop(\\x -> x * 2, 200)")-allocs("loop(\\x -> x * 2, 100)"), float64(2))
+}
+
+const benchmarkFib = "def fib n = if n < 2 then n else fib(n - 1) + fib(n - 2)"
+
+func benchmarkFibonacci(b *testing.B, walk bool) {
+	i := newTestInterpreter()
+	i.walk = walk
+	if _, err := i.Execute(benchmarkFib); err != nil {
+		b.Fatal(err)
+	}
+	node, err := i.Parse("fib(15)")
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		if _, err := i.Eval(node); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkFibonacciTreeWalk(b *testing.B) {
+	benchmarkFibonacci(b, true)
+}
+
+func BenchmarkFibonacciVM(b *testing.B) {
+	benchmarkFibonacci(b, false)
+}
+
+const benchmarkLoop = "def loop f n = if n < 1 then 0 else f(n) + loop(f, n - 1)"
+
+// benchmarkClosure calls a lambda from a statement which is walked
+func benchmarkClosure(b *testing.B, walk bool) {
+	i := newTestInterpreter()
+	i.walk = walk
+	if _, err := i.Execute(benchmarkLoop); err != nil {
+		b.Fatal(err)
+	}
+	node, err := i.Parse("loop(\\x -> x * 2, 100)")
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		if _, err := i.Eval(node); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkClosureTreeWalk(b *testing.B) {
+	benchmarkClosure(b, true)
+}
+
+func BenchmarkClosureVM(b *testing.B) {
+	benchmarkClosure(b, false)
+}
+
+func benchmarkCompiled(b *testing.B, walk bool) {
+	i := newTestInterpreter()
+	i.walk = walk
+	p, err := i.Compile(benchmarkFormula)
+	if err != nil {
+		b.Fatal(err)
+	}
+	vars := map[string]int{"price": 3, "qty": 4}
+
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		if _, err := p.Eval(vars); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkFormulaTreeWalk(b *testing.B) {
+	benchmarkCompiled(b, true)
+}
+
+func BenchmarkFormulaVM(b *testing.B) {
+	benchmarkCompiled(b, false)
+}